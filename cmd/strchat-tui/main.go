@@ -6,15 +6,24 @@ import (
 	"log"
 	"os"
 
-	"github.com/lessucettes/strchat-tui/internal/client"
+	"github.com/lessucettes/strchat-tui/internal/headless"
 	"github.com/lessucettes/strchat-tui/internal/tui"
+	"github.com/lessucettes/strchat-tui/strchat"
 )
 
-var version = "dev"
+// Set at build time by the magefile.
+var (
+	version = "dev"
+	commit  = "local"
+	date    = ""
+)
 
 func main() {
 	versionFlag := flag.Bool("version", false, "Print the version and exit")
 	vFlag := flag.Bool("v", false, "Print the version and exit (shorthand)")
+	noColorFlag := flag.Bool("no-color", false, "Draw the interface without colors")
+	configFlag := flag.String("config", "", "Directory for the config and all other state files")
+	headlessFlag := flag.Bool("headless", false, "Read actions from stdin and print events to stdout as JSON lines instead of starting the TUI")
 	flag.Parse()
 
 	if *versionFlag || *vFlag {
@@ -22,15 +31,33 @@ func main() {
 		os.Exit(0)
 	}
 
-	actionsChan := make(chan client.UserAction, 10)
-	eventsChan := make(chan client.DisplayEvent, 10)
+	if *configFlag != "" {
+		if err := strchat.SetConfigDir(*configFlag); err != nil {
+			log.Fatalf("Failed to set config directory: %v", err)
+		}
+	}
+
+	actionsChan := make(chan strchat.UserAction, 10)
+	eventsChan := make(chan strchat.DisplayEvent, 10)
 
-	nostrClient, err := client.New(actionsChan, eventsChan)
+	nostrClient, err := strchat.New(actionsChan, eventsChan)
 	if err != nil {
 		log.Fatalf("Failed to create nostr client: %v", err)
 	}
 
+	if *headlessFlag {
+		go nostrClient.Run()
+		if err := headless.New(actionsChan, eventsChan, os.Stdin, os.Stdout).Run(); err != nil {
+			log.Fatalf("Failed to read input: %v", err)
+		}
+		return
+	}
+
 	appUI := tui.New(actionsChan, eventsChan)
+	appUI.SetVersion(version, commit, date)
+	if *noColorFlag {
+		appUI.NoColor()
+	}
 
 	go nostrClient.Run()
 
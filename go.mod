@@ -10,7 +10,10 @@ require (
 	github.com/rivo/tview v0.0.0-20250827170525-39dce1f64fd6
 )
 
-require github.com/btcsuite/btcd/btcutil v1.1.5 // indirect
+require (
+	github.com/btcsuite/btcd/btcutil v1.1.5 // indirect
+	golang.org/x/crypto v0.36.0 // indirect
+)
 
 require (
 	github.com/ImVexed/fasturl v0.0.0-20230304231329-4e41488060f3 // indirect
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Chat Logging
+
+// chatLogDir returns the directory chat logs are written to, or "" when logging is off.
+func (c *client) chatLogDir() string {
+	if c.config.DisableLog {
+		return ""
+	}
+	return c.config.LogDir
+}
+
+// logMessage appends a displayed message to <LogDir>/<chat>.log. When the file
+// exceeds maxChatLogSize it is rotated to <chat>.log.1, replacing an older one.
+func (c *client) logMessage(ev *nostr.Event, chat, nick, spk, content string) {
+	dir := c.chatLogDir()
+	if dir == "" || chat == "" {
+		return
+	}
+
+	c.chatLogMu.Lock()
+	defer c.chatLogMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Printf("Could not create log dir %s: %v", dir, err)
+		return
+	}
+	path := filepath.Join(dir, chatLogFileName(chat))
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= maxChatLogSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			log.Printf("Could not rotate %s: %v", path, err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Could not open chat log %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+
+	ts := time.Unix(int64(ev.CreatedAt), 0).Format("2006-01-02 15:04:05")
+	content = strings.ReplaceAll(content, "\n", "\n    ")
+	if _, err := fmt.Fprintf(f, "%s %s#%s: %s\n", ts, nick, spk, content); err != nil {
+		log.Printf("Could not write chat log %s: %v", path, err)
+	}
+}
+
+// chatLogFileName maps a chat name to a safe file name.
+func chatLogFileName(chat string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, chat)
+	if name == "." || name == ".." {
+		name = "_" + name
+	}
+	return name + ".log"
+}
+
+// setChatLog reports or toggles chat logging. Turning it on without a LogDir
+// logs to the "logs" directory next to config.json.
+func (c *client) setChatLog(payload string) {
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "":
+		if dir := c.chatLogDir(); dir != "" {
+			c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Chat logging is on, writing to %s.", dir)}
+		} else {
+			c.eventsChan <- DisplayEvent{Type: "INFO", Content: "Chat logging is off."}
+		}
+	case "on":
+		if c.config.LogDir == "" {
+			appConfigDir, err := getAppConfigDir()
+			if err != nil {
+				c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not determine log dir: %v", err)}
+				return
+			}
+			c.config.LogDir = filepath.Join(appConfigDir, "logs")
+		}
+		c.config.DisableLog = false
+		c.saveConfig()
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Chat logging enabled, writing to %s.", c.config.LogDir)}
+	case "off":
+		c.config.DisableLog = true
+		c.saveConfig()
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Chat logging disabled."}
+	default:
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /log [on|off]"}
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
 )
 
 type client struct {
@@ -21,10 +23,17 @@ type client struct {
 	config   *config
 	chatKeys map[string]chatSession
 
+	// Views removed recently, newest last, for /undo
+	undoStack []removedView
+
 	// TUI I/O
 	actionsChan <-chan UserAction
 	eventsChan  chan<- DisplayEvent
 
+	// Actions the client posts to its own action loop, see post
+	internalActions chan UserAction
+	dmRumors        chan nostr.Event // Decrypted DMs, shown by showDM
+
 	// Client Lifecycle
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -32,19 +41,36 @@ type client struct {
 
 	// Relay State
 	relays   map[string]*managedRelay
-	relaysMu sync.Mutex // Protects relays
+	relaysMu sync.Mutex // Protects relays, lastRelayCap
+
+	// Relays kept and candidate count of the last relay cap, see capRelays
+	lastRelayCap string
+
+	// URLs picked as geo relays, for RelayInfo.Type -> the chats picking them
+	geoRelays   map[string]map[string]struct{}
+	geoRelaysMu sync.Mutex // Protects geoRelays
+
+	// Relay Metadata (NIP-11)
+	relayInfo   map[string]nip11.RelayInformationDocument
+	relayInfoMu sync.Mutex     // Protects relayInfo
+	autoPoW     map[string]int // Min PoW advertised by each chat's relay pool
+	autoPoWMu   sync.Mutex     // Protects autoPoW
 
 	// Event Processing State
-	seenCache   *lru.Cache[string, bool]
-	seenCacheMu sync.Mutex // Protects seenCache
-	userContext *lru.Cache[string, userContext]
-	orderBuf    map[string][]orderItem
-	orderTimers map[string]*time.Timer
-	orderMu     sync.Mutex // Protects orderBuf, orderTimers
+	seenCache    *lru.Cache[string, eventState] // Event ID -> how far it got towards the TUI
+	seenCacheMu  sync.Mutex                     // Protects seenCache
+	userContext  *lru.Cache[string, userContext]
+	recentEvents *lru.Cache[string, recentEvent] // Event ID -> event, for /reply
+	dmRelayLists *lru.Cache[string, dmRelayList] // Pubkey -> its NIP-17 DM relays
+	orderBuf     map[string][]orderItem
+	orderTimers  map[string]*time.Timer
+	orderMu      sync.Mutex // Protects orderBuf, orderTimers
+	stats        clientStats
+	debug        atomic.Bool // Show dropped messages with the reason instead of hiding them
 
 	// Relay Discovery State
 	discoveredStore   *discoveredRelayStore
-	verifyFailCache   *lru.Cache[string, bool]
+	verifyFailCache   *lru.Cache[string, int64] // Normalized URL -> unix time of the failure
 	verifying         map[string]struct{}
 	verifyingMu       sync.Mutex // Protects verifying
 	activeDiscoveries int32
@@ -52,8 +78,27 @@ type client struct {
 	updateSubMu       sync.Mutex // Protects updateSubTimer
 
 	// Moderation State
-	filtersCompiled []compiledPattern
-	mutesCompiled   []compiledPattern
+	filtersCompiled    []compiledPattern
+	mutesCompiled      []compiledPattern
+	highlightsCompiled []compiledPattern
+	floodCache         *lru.Cache[string, floodEntry] // Normalized content -> recent copies
+	floodMu            sync.Mutex                     // Serializes floodCache updates
+
+	// Chat Logging State
+	chatLogMu sync.Mutex // Serializes writes to chat log files
+
+	// Lookup State
+	lastWhoisMatches []string
+
+	// Publishing State
+	sendLimiter  *tokenBucket
+	localMsgSeq  atomic.Uint64 // Source of LocalIDs for own messages
+	warnedPublic atomic.Bool   // Public @nick warning was shown this session
+	outbox       map[string][]outboxItem
+	outboxMu     sync.Mutex                    // Protects outbox
+	mining       map[string]context.CancelFunc // LocalID -> cancels its PoW calculation
+	powOffsets   map[int]uint64                // Difficulty -> nonce to start the next mine at
+	miningMu     sync.Mutex                    // Protects mining, powOffsets
 }
 
 func New(actions <-chan UserAction, events chan<- DisplayEvent) (*client, error) {
@@ -66,7 +111,7 @@ func New(actions <-chan UserAction, events chan<- DisplayEvent) (*client, error)
 		cfg.BlockedUsers = []blockedUser{}
 	}
 
-	seenCache, err := lru.New[string, bool](seenCacheSize)
+	seenCache, err := lru.New[string, eventState](seenCacheSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create seen cache: %w", err)
 	}
@@ -76,25 +121,57 @@ func New(actions <-chan UserAction, events chan<- DisplayEvent) (*client, error)
 		return nil, fmt.Errorf("failed to create user context cache: %w", err)
 	}
 
-	verifyFailCache, err := lru.New[string, bool](2000)
+	recentEvents, err := lru.New[string, recentEvent](recentEventsSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recent events cache: %w", err)
+	}
+
+	dmRelayLists, err := lru.New[string, dmRelayList](dmRelayListsSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DM relay list cache: %w", err)
+	}
+
+	floodCache, err := lru.New[string, floodEntry](floodCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flood cache: %w", err)
+	}
+
+	verifyFailCache, err := lru.New[string, int64](2000)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create verify fail cache: %w", err)
 	}
 
+	maxMsgsPerMinute := cfg.MaxMsgsPerMinute
+	if maxMsgsPerMinute <= 0 {
+		maxMsgsPerMinute = defaultMaxMsgsPerMin
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &client{
 		config:          cfg,
 		actionsChan:     actions,
 		eventsChan:      events,
+		internalActions: make(chan UserAction, internalActionsSize),
+		dmRumors:        make(chan nostr.Event, internalActionsSize),
+		dmRelayLists:    dmRelayLists,
 		relays:          make(map[string]*managedRelay),
+		relayInfo:       make(map[string]nip11.RelayInformationDocument),
+		autoPoW:         make(map[string]int),
+		geoRelays:       make(map[string]map[string]struct{}),
 		seenCache:       seenCache,
 		userContext:     userContextCache,
+		recentEvents:    recentEvents,
+		floodCache:      floodCache,
 		chatKeys:        make(map[string]chatSession),
 		orderBuf:        make(map[string][]orderItem),
 		orderTimers:     make(map[string]*time.Timer),
+		outbox:          make(map[string][]outboxItem),
+		mining:          make(map[string]context.CancelFunc),
+		powOffsets:      make(map[int]uint64),
 		verifying:       make(map[string]struct{}),
 		verifyFailCache: verifyFailCache,
+		sendLimiter:     newTokenBucket(maxMsgsPerMinute),
 		ctx:             ctx,
 		cancel:          cancel,
 	}
@@ -102,8 +179,10 @@ func New(actions <-chan UserAction, events chan<- DisplayEvent) (*client, error)
 	if err := client.loadDiscoveredRelayStore(); err != nil {
 		return nil, fmt.Errorf("failed to load relay store: %w", err)
 	}
+	client.loadFailCache()
 
 	client.rebuildRegexCaches()
+	client.loadChatIdentities()
 
 	if cfg.Nick != "" {
 		client.n = cfg.Nick
@@ -154,8 +233,10 @@ func (c *client) Run() {
 
 	c.wg.Go(func() {
 		c.updateAllSubscriptions()
-		c.discoverRelays(c.config.AnchorRelays, 1)
+		c.discoverRelays(c.anchorsFor(anchorRead), 1)
 	})
+	c.wg.Go(c.runMuteExpiry)
+	c.wg.Go(c.runUserContextSweep)
 
 	for {
 		select {
@@ -165,6 +246,10 @@ func (c *client) Run() {
 				return
 			}
 			c.handleAction(action)
+		case action := <-c.internalActions:
+			c.handleAction(action)
+		case rumor := <-c.dmRumors:
+			c.showDM(&rumor)
 		case <-c.ctx.Done():
 			return
 		}
@@ -175,6 +260,25 @@ func (c *client) handleAction(action UserAction) {
 	switch action.Type {
 	case "SEND_MESSAGE":
 		go c.publishMessage(action.Payload)
+	case "CANCEL_POW":
+		c.cancelPoW()
+	case "SEND_REPLY":
+		go c.publishReply(action.Payload)
+	case "SET_DMS":
+		c.setDMs(action.Payload)
+	case "SEND_DM":
+		pk, message, _ := strings.Cut(action.Payload, " ")
+		c.sendDM(pk, message)
+	case "LOAD_HISTORY":
+		go c.loadHistory(action.Payload)
+	case "RELAY_INFO":
+		go c.showRelayInfo(action.Payload)
+	case "DROP_RELAY":
+		c.dropRelay(action.Payload)
+	case "RECONNECT_RELAY":
+		go c.reconnectRelay(action.Payload)
+	case "PUBLISH_RELAY_LIST":
+		go c.publishRelayList()
 	case "ACTIVATE_VIEW":
 		c.setActiveView(action.Payload)
 		c.flushAllOrdering()
@@ -183,28 +287,54 @@ func (c *client) handleAction(action UserAction) {
 		c.createGroup(action.Payload)
 	case "JOIN_CHATS":
 		c.joinChats(action.Payload)
+	case "JOIN_NEAR":
+		c.joinNear(action.Payload)
+	case "ZOOM_CHAT":
+		c.zoomChat(action.Payload)
 	case "LEAVE_CHAT":
 		c.leaveChat(action.Payload)
 	case "DELETE_GROUP":
 		c.deleteGroup(action.Payload)
 	case "DELETE_VIEW":
 		c.deleteView(action.Payload)
+	case "UNDO_REMOVE":
+		c.undoRemove()
+	case "LIST_PRESENT":
+		c.listPresent(action.Payload)
+	case "SET_CHAT_LOG":
+		c.setChatLog(action.Payload)
 	case "REQUEST_NICK_COMPLETION":
 		c.handleNickCompletion(action.Payload)
 	case "SET_POW":
 		c.setPoW(action.Payload)
+	case "SET_BROADCAST":
+		c.setBroadcast(action.Payload)
+	case "SET_NOTIFY":
+		c.setNotify(action.Payload)
 	case "SET_NICK":
 		c.setNick(action.Payload)
+	case "SET_TIME_FORMAT":
+		c.setTimestampFormat(action.Payload)
+	case "SET_LAYOUT":
+		c.setLayout(action.Payload)
+	case "SET_THEME":
+		c.setTheme(action.Payload)
+	case "IMPORT_KEY":
+		c.importKey(action.Payload)
+	case "EXPORT_KEY":
+		c.exportKey(action.Payload)
 	case "LIST_CHATS":
 		c.listChats()
 	case "GET_ACTIVE_CHAT":
 		c.getActiveChat()
 	case "BLOCK_USER":
-		c.blockUser(action.Payload)
+		c.handleBlock(action.Payload)
 	case "UNBLOCK_USER":
 		c.unblockUser(action.Payload)
 	case "LIST_BLOCKED":
 		c.listBlockedUsers()
+	case "WHOIS":
+		c.whois(action.Payload)
 	case "HANDLE_FILTER":
 		c.handleFilter(action.Payload)
 	case "REMOVE_FILTER":
@@ -217,18 +347,44 @@ func (c *client) handleAction(action UserAction) {
 		c.removeMute(action.Payload)
 	case "CLEAR_MUTES":
 		c.clearMutes()
+	case "HANDLE_HIGHLIGHT":
+		c.handleHighlight(action.Payload)
+	case "REMOVE_HIGHLIGHT":
+		c.removeHighlight(action.Payload)
+	case "CLEAR_HIGHLIGHTS":
+		c.clearHighlights()
 	case "MANAGE_ANCHORS":
 		c.manageAnchors(action.Payload)
+	case "SET_DISCOVERY":
+		c.setDiscovery(action.Payload)
+	case "SET_GEO_RELAY_COUNT":
+		c.setGeoRelayCount(action.Payload)
+	case "SET_DEBUG":
+		c.setDebug(action.Payload)
+	case "GET_STATS":
+		c.showStats()
 	case "GET_HELP":
 		c.getHelp()
+	case "EXPIRE_MUTES":
+		c.expireMutes()
 	case "QUIT":
 		c.shutdown()
 	}
 }
 
-// manageAnchors handles adding/removing/listing anchor relays.
+// manageAnchors handles adding/removing/listing anchor relays. A trailing
+// read, write or both sets the intent of the given relays, including ones
+// that are already anchors.
 func (c *client) manageAnchors(payload string) {
 	args := strings.Fields(payload)
+	intent := ""
+	if n := len(args); n > 1 {
+		switch strings.ToLower(args[n-1]) {
+		case anchorRead, anchorWrite, anchorBoth:
+			intent = strings.ToLower(args[n-1])
+			args = args[:n-1]
+		}
+	}
 
 	if len(args) == 0 {
 		if len(c.config.AnchorRelays) == 0 {
@@ -238,13 +394,17 @@ func (c *client) manageAnchors(payload string) {
 		var builder strings.Builder
 		builder.WriteString("Anchor Relays:\n")
 		for i, url := range c.config.AnchorRelays {
-			builder.WriteString(fmt.Sprintf("[%d] %s\n", i+1, url))
+			if in := c.anchorIntent(url); in != anchorBoth {
+				builder.WriteString(fmt.Sprintf("[%d] %s (%s only)\n", i+1, url, in))
+			} else {
+				builder.WriteString(fmt.Sprintf("[%d] %s\n", i+1, url))
+			}
 		}
 		c.eventsChan <- DisplayEvent{Type: "INFO", Content: builder.String()}
 		return
 	}
 
-	if len(args) == 1 {
+	if len(args) == 1 && intent == "" {
 		idx, err := strconv.Atoi(args[0])
 		if err == nil {
 			if idx < 1 || idx > len(c.config.AnchorRelays) {
@@ -253,6 +413,7 @@ func (c *client) manageAnchors(payload string) {
 			}
 			removedURL := c.config.AnchorRelays[idx-1]
 			c.config.AnchorRelays = append(c.config.AnchorRelays[:idx-1], c.config.AnchorRelays[idx:]...)
+			delete(c.config.AnchorIntents, removedURL)
 			c.saveConfig()
 			c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Removed anchor relay: %s", removedURL)}
 			go c.updateAllSubscriptions()
@@ -260,7 +421,7 @@ func (c *client) manageAnchors(payload string) {
 		}
 	}
 
-	var added []string
+	var added, changed []string
 	var invalid []string
 	existingAnchors := make(map[string]struct{}, len(c.config.AnchorRelays))
 	for _, anchor := range c.config.AnchorRelays {
@@ -268,16 +429,24 @@ func (c *client) manageAnchors(payload string) {
 	}
 
 	for _, rawURL := range args {
+		if idx, err := strconv.Atoi(rawURL); err == nil && intent != "" && idx >= 1 && idx <= len(c.config.AnchorRelays) {
+			rawURL = c.config.AnchorRelays[idx-1]
+		}
 		url, err := normalizeRelayURL(rawURL)
 		if err != nil {
 			invalid = append(invalid, rawURL)
 			continue
 		}
 		if _, exists := existingAnchors[url]; exists {
+			if intent != "" && c.anchorIntent(url) != intent {
+				c.setAnchorIntent(url, intent)
+				changed = append(changed, url)
+			}
 			continue
 		}
 
 		c.config.AnchorRelays = append(c.config.AnchorRelays, url)
+		c.setAnchorIntent(url, intent)
 		existingAnchors[url] = struct{}{}
 		added = append(added, url)
 	}
@@ -286,18 +455,59 @@ func (c *client) manageAnchors(payload string) {
 		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid URL(s) skipped: %s", strings.Join(invalid, ", "))}
 	}
 
+	if len(changed) > 0 {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Set anchor relay(s) %s to %s.", strings.Join(changed, ", "), intent)}
+	}
 	if len(added) > 0 {
-		c.saveConfig()
 		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Added anchor relay(s): %s", strings.Join(added, ", "))}
+	}
+	if len(added) > 0 || len(changed) > 0 {
+		c.saveConfig()
+		var discover []string
+		for _, url := range added {
+			if c.anchorIntent(url) != anchorWrite {
+				discover = append(discover, url)
+			}
+		}
 		go func() {
 			c.updateAllSubscriptions()
-			c.discoverRelays(added, 1)
+			c.discoverRelays(discover, 1)
 		}()
 	} else if len(invalid) == 0 {
 		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Specified relay(s) are already in the anchor list."}
 	}
 }
 
+// anchorIntent returns anchorRead, anchorWrite or anchorBoth for an anchor URL.
+func (c *client) anchorIntent(url string) string {
+	if intent := c.config.AnchorIntents[url]; intent == anchorRead || intent == anchorWrite {
+		return intent
+	}
+	return anchorBoth
+}
+
+func (c *client) setAnchorIntent(url, intent string) {
+	if intent == "" || intent == anchorBoth {
+		delete(c.config.AnchorIntents, url)
+		return
+	}
+	if c.config.AnchorIntents == nil {
+		c.config.AnchorIntents = make(map[string]string)
+	}
+	c.config.AnchorIntents[url] = intent
+}
+
+// anchorsFor returns the anchors usable for intent (anchorRead or anchorWrite).
+func (c *client) anchorsFor(intent string) []string {
+	var out []string
+	for _, url := range c.config.AnchorRelays {
+		if in := c.anchorIntent(url); in == anchorBoth || in == intent {
+			out = append(out, url)
+		}
+	}
+	return out
+}
+
 func (c *client) shutdown() {
 	c.cancel()
 	c.orderMu.Lock()
@@ -309,6 +519,9 @@ func (c *client) shutdown() {
 	c.orderTimers = make(map[string]*time.Timer)
 	c.orderMu.Unlock()
 	c.wg.Wait()
+	if err := c.saveFailCache(); err != nil {
+		log.Printf("Could not save relay fail cache: %v", err)
+	}
 	select {
 	case c.eventsChan <- DisplayEvent{Type: "SHUTDOWN"}:
 	case <-time.After(200 * time.Millisecond):
@@ -317,6 +530,16 @@ func (c *client) shutdown() {
 
 // Helpers
 
+// post queues an action for the action loop. Goroutines use it for work
+// that changes the config or the pattern caches, which only the action loop
+// may touch.
+func (c *client) post(action UserAction) {
+	select {
+	case c.internalActions <- action:
+	case <-c.ctx.Done():
+	}
+}
+
 // triggerSubUpdate safely resets a timer to call updateAllSubscriptions.
 func (c *client) triggerSubUpdate() {
 	c.updateSubMu.Lock()
@@ -330,6 +553,7 @@ func (c *client) triggerSubUpdate() {
 	c.updateSubTimer = time.AfterFunc(debounceDelay, func() {
 		c.updateAllSubscriptions()
 		_ = c.saveDiscoveredRelayStore()
+		_ = c.saveFailCache()
 
 		c.updateSubMu.Lock()
 		c.updateSubTimer = nil
@@ -3,6 +3,7 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -15,6 +16,12 @@ type View struct {
 	IsGroup  bool     `json:"is_group"`
 	Children []string `json:"children"`
 	PoW      int      `json:"pow,omitempty"`
+	DM       string   `json:"dm,omitempty"`     // pubkey of the other side of a DM view
+	Notify   string   `json:"notify,omitempty"` // NotifyAll, NotifyMentions (default) or NotifyNone
+
+	AllowBroadcast bool `json:"allow_broadcast,omitempty"` // group only: plain messages go to every child chat
+
+	Transient bool `json:"-"` // DM view opened by an incoming message, not saved
 }
 
 type blockedUser struct {
@@ -23,22 +30,96 @@ type blockedUser struct {
 }
 
 // filter defines a pattern and its current state (enabled/disabled).
+// A non-empty Chat limits the pattern to that chat, and a non-zero
+// ExpiresAt (unix seconds) makes it temporary.
 type filter struct {
-	Pattern string `json:"pattern"`
-	Enabled bool   `json:"enabled"`
+	Pattern   string `json:"pattern"`
+	Enabled   bool   `json:"enabled"`
+	Chat      string `json:"chat,omitempty"`
+	Flags     string `json:"flags,omitempty"` // "i" ignores case, "w" matches whole words
+	Field     string `json:"field,omitempty"` // fieldContent (default) or fieldNick
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// chatIdentity is a per-chat ephemeral identity saved when PersistIdentities is enabled.
+type chatIdentity struct {
+	PrivateKey string `json:"private_key"`
+	PubKey     string `json:"pubkey"`
+	Nick       string `json:"nick,omitempty"`
+}
+
+// configVersion is the schema version written to new and migrated config
+// files. Files without a version predate versioning and count as 0.
+const configVersion = 1
+
+// keyFileName holds the private key when KeyFile is set.
+const keyFileName = "key.json"
+
+// keyFile is the structure of the separate key file.
+type keyFile struct {
+	PrivateKey string `json:"private_key"`
 }
 
 // config is the main structure of the configuration file.
 type config struct {
-	PrivateKey     string        `json:"private_key"`
-	Nick           string        `json:"nick,omitempty"`
-	Views          []View        `json:"views"`
-	ActiveViewName string        `json:"active_view_name"`
-	AnchorRelays   []string      `json:"anchor_relays,omitempty"`
-	BlockedUsers   []blockedUser `json:"blocked_users,omitempty"`
-	Filters        []filter      `json:"filters,omitempty"`
-	Mutes          []filter      `json:"mutes,omitempty"`
-	path           string        `json:"-"`
+	Version            int                     `json:"version"`
+	PrivateKey         string                  `json:"private_key,omitempty"`
+	KeyFile            bool                    `json:"key_file,omitempty"` // keep PrivateKey in key.json instead
+	Nick               string                  `json:"nick,omitempty"`
+	ChatNicks          map[string]string       `json:"chat_nicks,omitempty"` // chat -> nick overriding Nick there
+	Views              []View                  `json:"views"`
+	ActiveViewName     string                  `json:"active_view_name"`
+	AnchorRelays       []string                `json:"anchor_relays,omitempty"`
+	AnchorIntents      map[string]string       `json:"anchor_intents,omitempty"` // anchor URL -> anchorRead or anchorWrite; absent means both
+	BlockedUsers       []blockedUser           `json:"blocked_users,omitempty"`
+	Filters            []filter                `json:"filters,omitempty"`
+	Mutes              []filter                `json:"mutes,omitempty"`
+	Highlights         []filter                `json:"highlights,omitempty"`
+	PersistIdentities  bool                    `json:"persist_identities,omitempty"`
+	ChatIdentities     map[string]chatIdentity `json:"chat_identities,omitempty"`
+	TimestampFormat    string                  `json:"timestamp_format,omitempty"`
+	ShortPubKeyLen     int                     `json:"short_pubkey_len,omitempty"`
+	MaxClockSkew       int                     `json:"max_clock_skew,omitempty"`
+	OrderingDelayMs    int                     `json:"ordering_delay_ms,omitempty"`
+	MaxMsgLen          int                     `json:"max_msg_len,omitempty"`
+	TruncateDisplay    int                     `json:"truncate_display,omitempty"`
+	HangingIndent      bool                    `json:"hanging_indent,omitempty"`
+	MessageLayout      string                  `json:"message_layout,omitempty"`
+	RelaySort          string                  `json:"relay_sort,omitempty"`
+	MaxMsgsPerMinute   int                     `json:"max_messages_per_minute,omitempty"`
+	PublishRetries     int                     `json:"publish_retries,omitempty"`
+	AutoPoW            bool                    `json:"auto_pow,omitempty"`
+	FirstSeenPoW       int                     `json:"first_seen_pow,omitempty"`
+	RelayPingInterval  int                     `json:"relay_ping_interval,omitempty"`
+	ConnectTimeoutSec  int                     `json:"connect_timeout,omitempty"`
+	SubscriptionLimit  int                     `json:"subscription_limit,omitempty"`
+	FailCacheTTL       string                  `json:"fail_cache_ttl,omitempty"`
+	UserContextTTL     string                  `json:"user_context_ttl,omitempty"`
+	MaxConnectedRelays int                     `json:"max_connected_relays,omitempty"`
+	DisableDiscovery   bool                    `json:"disable_discovery,omitempty"`
+	DisableGeoRelays   bool                    `json:"disable_geo_relays,omitempty"`
+	GeoRelaysPath      string                  `json:"geo_relays_path,omitempty"`
+	GeoRelaysURL       string                  `json:"geo_relays_url,omitempty"`
+	GeoRelayCount      int                     `json:"geo_relay_count,omitempty"`
+	StrictPatterns     bool                    `json:"strict_patterns,omitempty"`
+	FloodThreshold     int                     `json:"flood_threshold,omitempty"`
+	FloodWindow        int                     `json:"flood_window,omitempty"`
+	DMs                bool                    `json:"dms,omitempty"`
+	ConfirmDeletes     bool                    `json:"confirm_deletes,omitempty"`
+	RenderNostrRefs    bool                    `json:"render_nostr_refs,omitempty"`
+	Emoji              map[string]string       `json:"emoji,omitempty"`
+	LogDir             string                  `json:"log_dir,omitempty"`
+	DisableLog         bool                    `json:"disable_log,omitempty"`
+	BellOnMention      bool                    `json:"bell_on_mention,omitempty"`
+	SubscribeAllJoined bool                    `json:"subscribe_all_joined,omitempty"`
+	DisableURLOpen     bool                    `json:"disable_url_open,omitempty"`
+	Mouse              bool                    `json:"mouse,omitempty"`
+	Theme              string                  `json:"theme,omitempty"`
+	NickColors         string                  `json:"nick_colors,omitempty"`
+	OwnNickByPubKey    bool                    `json:"own_nick_by_pubkey,omitempty"`
+	NoColor            bool                    `json:"no_color,omitempty"`
+	Keybindings        map[string]string       `json:"keybindings,omitempty"`
+	path               string                  `json:"-"`
 }
 
 func loadConfig() (*config, error) {
@@ -50,22 +131,113 @@ func loadConfig() (*config, error) {
 	configPath := filepath.Join(appConfigDir, "config.json")
 	conf := &config{path: configPath}
 
-	file, err := os.Open(configPath)
+	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return createDefaultConfig(configPath)
 		}
 		return nil, fmt.Errorf("could not open config file: %w", err)
 	}
-	defer file.Close()
 
-	if err := json.NewDecoder(file).Decode(conf); err != nil {
+	migrated, oldVersion, err := migrateConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode config file: %w", err)
+	}
+	if err := json.Unmarshal(migrated, conf); err != nil {
 		return nil, fmt.Errorf("could not decode config file: %w", err)
 	}
 
+	inlineKey := conf.PrivateKey != ""
+	fileKey, err := conf.readKeyFile()
+	if err != nil {
+		return nil, err
+	}
+	movedInline := false
+	switch {
+	case fileKey == "":
+	case conf.KeyFile:
+		conf.PrivateKey = fileKey
+	case !inlineKey:
+		// key_file was turned off by hand: move the key back inline.
+		conf.PrivateKey = fileKey
+		movedInline = true
+	case fileKey != conf.PrivateKey:
+		log.Printf("Ignoring %s: key_file is off, so the private key in config.json is used.", conf.keyPath())
+	}
+
+	changed := oldVersion < configVersion
+	if changed {
+		backupPath := fmt.Sprintf("%s.v%d.bak", configPath, oldVersion)
+		if err := os.WriteFile(backupPath, data, 0600); err != nil {
+			return nil, fmt.Errorf("could not back up config file before migrating: %w", err)
+		}
+	}
+	// Rewriting also moves an inline key out once key_file is turned on.
+	if changed || movedInline || (conf.KeyFile && inlineKey && fileKey == "") {
+		if err := conf.save(); err != nil {
+			return nil, err
+		}
+	}
+
 	return conf, nil
 }
 
+// migrateConfig upgrades a config file written by an older version to
+// configVersion. It returns the version the file had; current files are
+// returned as they are.
+func migrateConfig(data []byte) ([]byte, int, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+	var version int
+	if v, ok := raw["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, 0, fmt.Errorf("invalid version: %w", err)
+		}
+	}
+	if version >= configVersion {
+		return data, version, nil
+	}
+
+	// v0: filters, mutes and highlights were plain pattern strings and
+	// blocked users bare pubkeys.
+	for _, key := range []string{"filters", "mutes", "highlights"} {
+		raw[key] = upgradeStrings(raw[key], func(s string) any { return filter{Pattern: s, Enabled: true} })
+	}
+	raw["blocked_users"] = upgradeStrings(raw["blocked_users"], func(s string) any { return blockedUser{PubKey: s} })
+
+	raw["version"], _ = json.Marshal(configVersion)
+	out, err := json.Marshal(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, version, nil
+}
+
+// upgradeStrings replaces the string elements of a JSON array with
+// upgrade(s). Anything else is returned unchanged.
+func upgradeStrings(msg json.RawMessage, upgrade func(string) any) json.RawMessage {
+	var items []json.RawMessage
+	if len(msg) == 0 || json.Unmarshal(msg, &items) != nil {
+		return msg
+	}
+	for i, item := range items {
+		var s string
+		if json.Unmarshal(item, &s) != nil {
+			continue
+		}
+		if b, err := json.Marshal(upgrade(s)); err == nil {
+			items[i] = b
+		}
+	}
+	out, err := json.Marshal(items)
+	if err != nil {
+		return msg
+	}
+	return out
+}
+
 // save writes the current configuration back to the file.
 func (c *config) save() error {
 	dirPerm := os.FileMode(0755)
@@ -80,43 +252,168 @@ func (c *config) save() error {
 		return fmt.Errorf("could not create config directory: %w", err)
 	}
 
-	file, err := os.OpenFile(c.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, filePerm)
-	if err != nil {
-		return fmt.Errorf("could not create config file: %w", err)
+	// Transient DM views only live for the session.
+	stripped := *c
+	stripped.Views = make([]View, 0, len(c.Views))
+	for _, v := range c.Views {
+		if !v.Transient {
+			stripped.Views = append(stripped.Views, v)
+		}
+		if v.Transient && v.Name == c.ActiveViewName {
+			stripped.ActiveViewName = ""
+		}
+	}
+	out := &stripped
+	if c.KeyFile {
+		key, _ := json.MarshalIndent(keyFile{PrivateKey: c.PrivateKey}, "", "  ")
+		if err := writeFileAtomic(c.keyPath(), append(key, '\n'), 0600); err != nil {
+			return fmt.Errorf("could not write key file: %w", err)
+		}
+		stripped.PrivateKey = ""
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(c); err != nil {
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
 		return fmt.Errorf("could not encode config file: %w", err)
 	}
+	if err := writeFileAtomic(c.path, append(data, '\n'), filePerm); err != nil {
+		return fmt.Errorf("could not write config file: %w", err)
+	}
 
+	// The key is inline again, so its old copy can go. A key file holding
+	// a different key is left alone rather than destroyed.
+	if !c.KeyFile {
+		fileKey, err := c.readKeyFile()
+		if err == nil && fileKey == c.PrivateKey {
+			if err := os.Remove(c.keyPath()); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("could not remove key file: %w", err)
+			}
+		}
+	}
 	return nil
 }
 
+// writeFileAtomic writes data to a temporary file and renames it over path,
+// so a crash mid-write never leaves a truncated file (and a lost key) behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeAtomic(path, perm, func(file *os.File) error {
+		_, err := file.Write(data)
+		return err
+	})
+}
+
+// writeAtomic calls write on a fresh temporary file next to path and renames
+// it over path once write succeeds. Every call gets its own temporary file,
+// so concurrent saves never clobber each other's half-written data.
+func writeAtomic(path string, perm os.FileMode, write func(*os.File) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	fail := func(err error) error {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Chmod(perm); err != nil {
+		return fail(err)
+	}
+	if err := write(file); err != nil {
+		return fail(err)
+	}
+	if err := file.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// keyPath returns the location of the separate key file next to config.json.
+func (c *config) keyPath() string {
+	return filepath.Join(filepath.Dir(c.path), keyFileName)
+}
+
+// readKeyFile returns the key stored in the separate key file, or "" if
+// there is none.
+func (c *config) readKeyFile() (string, error) {
+	data, err := os.ReadFile(c.keyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not open key file: %w", err)
+	}
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return "", fmt.Errorf("could not decode key file: %w", err)
+	}
+	return kf.PrivateKey, nil
+}
+
 // createDefaultConfig generates a new private key and a default config file.
 func createDefaultConfig(path string) (*config, error) {
 	sk := nostr.GeneratePrivateKey()
 	conf := &config{
+		Version:        configVersion,
 		PrivateKey:     sk,
 		Views:          []View{},
 		ActiveViewName: "",
 		AnchorRelays:   []string{},
 		BlockedUsers:   []blockedUser{},
 
-		Filters: []filter{},
-		Mutes:   []filter{},
+		Filters:    []filter{},
+		Mutes:      []filter{},
+		Highlights: []filter{},
 
 		path: path,
 	}
 	return conf, conf.save()
 }
 
+// configDirOverride replaces the default config directory when set.
+var configDirOverride string
+
+// SetConfigDir makes all configuration and state files live under dir
+// instead of the user config directory. It must be called before New.
+func SetConfigDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid config directory: %w", err)
+	}
+	configDirOverride = abs
+	return nil
+}
+
 func getAppConfigDir() (string, error) {
+	if configDirOverride != "" {
+		return configDirOverride, nil
+	}
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		return "", fmt.Errorf("could not get user config directory: %w", err)
 	}
 	return filepath.Join(configDir, "strchat-tui"), nil
 }
+
+// ConfigDir returns the directory holding the application's configuration files.
+func ConfigDir() (string, error) {
+	return getAppConfigDir()
+}
+
+// WriteConfigFile atomically writes data to the file name in the config
+// directory, for state kept next to config.json outside this package.
+func WriteConfigFile(name string, data []byte) error {
+	dir, err := getAppConfigDir()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, name), data, 0600)
+}
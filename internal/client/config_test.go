@@ -0,0 +1,161 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteAtomicInterruptedKeepsOriginal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte("original"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	errCrash := errors.New("crash")
+	err := writeAtomic(path, 0600, func(file *os.File) error {
+		if _, err := file.Write([]byte("half a ne")); err != nil {
+			return err
+		}
+		return errCrash
+	})
+	if !errors.Is(err, errCrash) {
+		t.Fatalf("writeAtomic error = %v, want %v", err, errCrash)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "original" {
+		t.Errorf("file = %q after interrupted write, want original content", data)
+	}
+	assertOnlyFile(t, dir, "config.json")
+}
+
+func TestWriteFileAtomicConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	const writers = 16
+	want := make(map[string]bool, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		content := fmt.Sprintf("content of writer %d", i)
+		want[content] = true
+		wg.Go(func() {
+			if err := writeFileAtomic(path, []byte(content), 0600); err != nil {
+				t.Errorf("writeFileAtomic: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !want[string(data)] {
+		t.Errorf("file = %q, want the complete content of one writer", data)
+	}
+	assertOnlyFile(t, dir, "config.json")
+}
+
+// assertOnlyFile fails unless dir holds exactly the file name.
+func assertOnlyFile(t *testing.T, dir, name string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != name {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %v, want only %s", names, name)
+	}
+}
+
+func TestLoadConfigKeyFile(t *testing.T) {
+	const inline, stored = "inline-key", "stored-key"
+	tests := []struct {
+		name     string
+		config   string
+		keyFile  bool
+		wantKey  string
+		wantFile bool // key.json still exists after loading
+	}{
+		{"off keeps inline key", `{"version":1,"private_key":"inline-key"}`, true, inline, true},
+		{"off moves stored key inline", `{"version":1}`, true, stored, false},
+		{"on uses key file", `{"version":1,"key_file":true,"private_key":"inline-key"}`, true, stored, true},
+		{"on moves inline key out", `{"version":1,"key_file":true,"private_key":"inline-key"}`, false, inline, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			setTestConfigDir(t, dir)
+			if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			keyPath := filepath.Join(dir, keyFileName)
+			if tt.keyFile {
+				if err := os.WriteFile(keyPath, []byte(`{"private_key":"stored-key"}`), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			conf, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.PrivateKey != tt.wantKey {
+				t.Errorf("PrivateKey = %q, want %q", conf.PrivateKey, tt.wantKey)
+			}
+			if _, err := os.Stat(keyPath); (err == nil) != tt.wantFile {
+				t.Errorf("key file exists = %v, want %v", err == nil, tt.wantFile)
+			}
+		})
+	}
+}
+
+// setTestConfigDir points the config directory at dir for the test.
+func setTestConfigDir(t *testing.T, dir string) {
+	t.Helper()
+	old := configDirOverride
+	if err := SetConfigDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { configDirOverride = old })
+}
+
+func TestLoadConfigMigrationBackup(t *testing.T) {
+	dir := t.TempDir()
+	setTestConfigDir(t, dir)
+	old := []byte(`{"private_key":"k","mutes":["spam"]}`)
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), old, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Version != configVersion {
+		t.Errorf("Version = %d, want %d", conf.Version, configVersion)
+	}
+	if len(conf.Mutes) != 1 || conf.Mutes[0].Pattern != "spam" || !conf.Mutes[0].Enabled {
+		t.Errorf("Mutes = %+v, want one enabled spam pattern", conf.Mutes)
+	}
+	backup, err := os.ReadFile(filepath.Join(dir, "config.json.v0.bak"))
+	if err != nil {
+		t.Fatalf("no backup named after the old version: %v", err)
+	}
+	if string(backup) != string(old) {
+		t.Errorf("backup = %q, want the original file", backup)
+	}
+}
@@ -0,0 +1,401 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip44"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+// Direct Messages (NIP-17)
+
+// setDMs reports or toggles DM mode, or opens a DM view with a user and
+// optionally sends them a message: /dm [on|off|<@nick|npub|pubkey> [message]].
+func (c *client) setDMs(payload string) {
+	payload = strings.TrimSpace(payload)
+	switch strings.ToLower(payload) {
+	case "":
+		state := "off"
+		if c.config.DMs {
+			state = "on"
+		}
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("DM mode is %s.", state)}
+		return
+	case "on", "off":
+		c.config.DMs = strings.EqualFold(payload, "on")
+		c.saveConfig()
+		if c.config.DMs {
+			c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "DM mode enabled. @nick messages are now sent encrypted."}
+		} else {
+			c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "DM mode disabled."}
+		}
+		c.triggerSubUpdate()
+		return
+	}
+
+	if !c.config.DMs {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "DM mode is off. Enable it with /dm on."}
+		return
+	}
+
+	who, message, _ := strings.Cut(payload, " ")
+	pk, ok := c.resolveUser(who)
+	if !ok {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not find user matching '%s'.", who)}
+		return
+	}
+	if pk == c.pk {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Cannot send a DM to yourself."}
+		return
+	}
+
+	name := c.ensureDMView(pk, true)
+	c.setActiveView(name)
+	if strings.TrimSpace(message) != "" {
+		c.sendDM(pk, message)
+	}
+}
+
+// resolveUser maps an npub, hex pubkey or @nick#abcd prefix of a known user to a pubkey.
+func (c *client) resolveUser(s string) (string, bool) {
+	if pk, ok := parsePubKey(s); ok {
+		return pk, true
+	}
+	if !strings.HasPrefix(s, "@") {
+		return "", false
+	}
+	for _, pk := range c.userContext.Keys() {
+		if ctx, ok := c.userContext.Get(pk); ok {
+			if strings.HasPrefix(fmt.Sprintf("@%s#%s", ctx.nick, ctx.shortPubKey), s) {
+				return pk, true
+			}
+		}
+	}
+	return "", false
+}
+
+// dmViewName returns the name of the DM view with pk: the existing one, or
+// "dm:" and the shortest prefix of pk, in steps of 8 chars, that no other
+// view uses.
+func (c *client) dmViewName(pk string) string {
+	if v := c.dmView(pk); v != nil {
+		return v.Name
+	}
+	for n := 8; n < len(pk); n += 8 {
+		name := dmViewPrefix + pk[:n]
+		if !slices.ContainsFunc(c.config.Views, func(v View) bool { return v.Name == name }) {
+			return name
+		}
+	}
+	return dmViewPrefix + pk
+}
+
+// dmView returns the DM view with pk, or nil.
+func (c *client) dmView(pk string) *View {
+	for i := range c.config.Views {
+		if c.config.Views[i].DM == pk {
+			return &c.config.Views[i]
+		}
+	}
+	return nil
+}
+
+// ensureDMView returns the name of the DM view with pk, creating it if
+// needed. Unless persist is set, i.e. the user opened the conversation or
+// wrote in it, a new view is transient: it is not saved, and only the newest
+// maxTransientDMViews of them are kept.
+func (c *client) ensureDMView(pk string, persist bool) string {
+	if v := c.dmView(pk); v != nil {
+		if persist && v.Transient {
+			v.Transient = false
+			c.saveConfig()
+		}
+		return v.Name
+	}
+	name := c.dmViewName(pk)
+	if !persist {
+		c.evictTransientDMViews(maxTransientDMViews - 1)
+	}
+	c.config.Views = append(c.config.Views, View{Name: name, DM: pk, Transient: !persist})
+	if persist {
+		c.saveConfig()
+	}
+	c.sendStateUpdate()
+	return name
+}
+
+// evictTransientDMViews removes the oldest transient DM views, except the
+// active one, until at most keep are left.
+func (c *client) evictTransientDMViews(keep int) {
+	n := 0
+	for _, v := range c.config.Views {
+		if v.Transient {
+			n++
+		}
+	}
+	if n <= keep {
+		return
+	}
+	kept := make([]View, 0, len(c.config.Views))
+	for _, v := range c.config.Views {
+		if n > keep && v.Transient && v.Name != c.config.ActiveViewName {
+			n--
+			continue
+		}
+		kept = append(kept, v)
+	}
+	c.config.Views = kept
+}
+
+// dmFilter matches gift wraps addressed to our main key. Wraps are
+// backdated by up to two days, so Since reaches back that far.
+func (c *client) dmFilter() nostr.Filter {
+	since := nostr.Now() - nostr.Timestamp(giftWrapLookback/time.Second)
+	return nostr.Filter{
+		Kinds: []int{nostr.KindGiftWrap},
+		Tags:  nostr.TagMap{"p": []string{c.pk}},
+		Since: &since,
+	}
+}
+
+// giftWrap seals rumor for recipient with our main key.
+func (c *client) giftWrap(rumor nostr.Event, recipient string) (nostr.Event, error) {
+	key, err := nip44.GenerateConversationKey(recipient, c.sk)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	return nip59.GiftWrap(
+		rumor,
+		recipient,
+		func(s string) (string, error) { return nip44.Encrypt(s, key) },
+		func(e *nostr.Event) error { return e.Sign(c.sk) },
+		nil,
+	)
+}
+
+// sendDM shows an encrypted NIP-17 message to pk in the DM view and
+// publishes it in the background to pk's DM relays, plus a copy to
+// ourselves. It runs on the action loop, which owns the views; goroutines
+// post a SEND_DM action instead.
+func (c *client) sendDM(pk, message string) {
+	if strings.TrimSpace(sanitizeString(message)) == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Cannot send an empty message."}
+		return
+	}
+	if !c.config.DMs {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "DM mode is off. Enable it with /dm on."}
+		return
+	}
+	relays := c.publishRelays(dmInboxChat)
+	if len(relays) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Not connected to any relays for DMs."}
+		return
+	}
+
+	rumor := nostr.Event{
+		PubKey:    c.pk,
+		CreatedAt: nostr.Now(),
+		Kind:      nostr.KindDirectMessage,
+		Tags:      nostr.Tags{{"p", pk}},
+		Content:   message,
+	}
+	rumor.ID = rumor.GetID()
+
+	toThem, err := c.giftWrap(rumor, pk)
+	if err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Failed to encrypt DM: %v", err)}
+		return
+	}
+	toUs, err := c.giftWrap(rumor, c.pk)
+	if err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Failed to encrypt DM: %v", err)}
+		return
+	}
+
+	c.seenCacheMu.Lock()
+	c.seenCache.Add(rumor.ID, eventFlushed)
+	c.seenCache.Add(toUs.ID, eventFlushed)
+	c.seenCacheMu.Unlock()
+
+	name := c.ensureDMView(pk, true)
+	c.showOwnMessage(&rumor, name, "")
+	c.wg.Go(func() {
+		inbox := c.dmRelays(pk, relays)
+		defer closeEphemeral(inbox)
+		if len(inbox) == 0 {
+			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not reach any DM relay of %s.", name)}
+			return
+		}
+		c.sendToRelays(toThem, name, inbox)
+	})
+	for _, r := range relays {
+		c.wg.Go(func() {
+			if err := r.relay.Publish(c.ctx, toUs); err != nil {
+				log.Printf("Could not store own DM copy on %s: %v", r.url, err)
+			}
+		})
+	}
+}
+
+// dmRelays returns the relays to deliver a DM to pk on. NIP-17 senders use
+// the DM relays pk lists in its kind 10050 event; without such a list the
+// message goes to ours. Listed relays that are not connected are dialed for
+// the publish, so the caller closes the result with closeEphemeral.
+func (c *client) dmRelays(pk string, ours []*managedRelay) []*managedRelay {
+	urls := c.dmRelayList(pk, ours)
+	if len(urls) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("%s has no DM relay list. Sending to your DM relays.", c.dmViewName(pk))}
+		return ours
+	}
+
+	var relays []*managedRelay
+	var dial []string
+	c.relaysMu.Lock()
+	for _, url := range urls {
+		if r, ok := c.relays[url]; ok && !c.relayFailed(url) {
+			relays = append(relays, r)
+		} else {
+			dial = append(dial, url)
+		}
+	}
+	c.relaysMu.Unlock()
+	return append(relays, c.dialEphemeral(dial)...)
+}
+
+// dmRelayList returns the DM relays of pk's newest kind 10050 event, looked
+// up on relays and cached for dmRelayListTTL. It returns nil if pk has none.
+func (c *client) dmRelayList(pk string, relays []*managedRelay) []string {
+	if l, ok := c.dmRelayLists.Get(pk); ok && time.Since(l.fetchedAt) < dmRelayListTTL {
+		return l.urls
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, historyTimeout)
+	defer cancel()
+	filter := nostr.Filter{Kinds: []int{nostr.KindDMRelayList}, Authors: []string{pk}, Limit: 1}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		newest *nostr.Event
+	)
+	for _, r := range relays {
+		wg.Go(func() {
+			events, err := r.relay.QuerySync(ctx, filter)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, ev := range events {
+				if ev.PubKey == pk && (newest == nil || ev.CreatedAt > newest.CreatedAt) {
+					newest = ev
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	var urls []string
+	if newest != nil {
+		for _, tag := range newest.Tags {
+			if len(tag) < 2 || tag[0] != "relay" {
+				continue
+			}
+			if url, err := normalizeRelayURL(tag[1]); err == nil && !slices.Contains(urls, url) {
+				urls = append(urls, url)
+			}
+		}
+	}
+	if c.ctx.Err() == nil {
+		c.dmRelayLists.Add(pk, dmRelayList{urls: urls, fetchedAt: time.Now()})
+	}
+	return urls
+}
+
+// processGiftWrap decrypts an incoming NIP-17 message and hands it to the
+// action loop, which shows it with showDM.
+func (c *client) processGiftWrap(ev *nostr.Event) {
+	rumor, err := nip59.GiftUnwrap(*ev, func(otherPubKey, ciphertext string) (string, error) {
+		key, err := nip44.GenerateConversationKey(otherPubKey, c.sk)
+		if err != nil {
+			return "", err
+		}
+		return nip44.Decrypt(ciphertext, key)
+	})
+	if err != nil {
+		log.Printf("Could not unwrap DM %s: %v", safeSuffix(ev.ID, 4), err)
+		return
+	}
+	if rumor.Kind != nostr.KindDirectMessage {
+		return
+	}
+	select {
+	case c.dmRumors <- rumor:
+	case <-c.ctx.Done():
+	}
+}
+
+// showDM shows a decrypted DM in the view with its sender, creating a
+// transient view on first contact. Our own copies, sent from another
+// device, persist the view as a reply would.
+func (c *client) showDM(rumor *nostr.Event) {
+	partner := rumor.PubKey
+	if partner == c.pk {
+		pTag := rumor.Tags.Find("p")
+		if len(pTag) < 2 || !nostr.IsValidPublicKey(pTag[1]) {
+			return
+		}
+		partner = pTag[1]
+	}
+	for _, blockedUser := range c.config.BlockedUsers {
+		if partner == blockedUser.PubKey {
+			c.stats.droppedBlock.Add(1)
+			return
+		}
+	}
+
+	c.seenCacheMu.Lock()
+	if c.seenCache.Contains(rumor.ID) {
+		c.seenCacheMu.Unlock()
+		return
+	}
+	c.seenCache.Add(rumor.ID, eventSeen)
+	c.seenCacheMu.Unlock()
+
+	content := sanitizeString(rumor.Content)
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	nick, spk := c.eventNick(rumor)
+	if u, ok := c.userContext.Get(rumor.PubKey); ok {
+		nick = u.nick
+	}
+	if c.matchesAny(content, nick, patternsForChat(c.mutesCompiled, c.dmViewName(partner))) {
+		c.stats.droppedMute.Add(1)
+		return
+	}
+
+	name := c.ensureDMView(partner, rumor.PubKey == c.pk)
+	c.logMessage(rumor, name, nick, spk, content)
+	display, raw := c.displayContent(content, rumor.Tags)
+	c.stats.displayed.Add(1)
+	c.enqueueOrdered("chat:"+name, DisplayEvent{
+		Type:         "NEW_MESSAGE",
+		Timestamp:    time.Unix(int64(rumor.CreatedAt), 0).Format(c.timestampFormat()),
+		Nick:         nick,
+		FullPubKey:   rumor.PubKey,
+		ShortPubKey:  spk,
+		IsOwnMessage: rumor.PubKey == c.pk,
+		Content:      display,
+		RawContent:   raw,
+		ID:           safeSuffix(rumor.ID, 4),
+		FullID:       rumor.ID,
+		Chat:         name,
+	}, int64(rumor.CreatedAt), rumor.ID)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
+	"log"
 	"math"
 	"net/http"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mmcloughlin/geohash"
@@ -29,6 +31,9 @@ const (
 	cacheTTL      = 24 * time.Hour
 )
 
+// badLocalOnce limits the warning about an unusable local geo relays file to one per run.
+var badLocalOnce sync.Once
+
 // haversine calculates the great-circle distance in kilometers between two points on the Earth.
 func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	const (
@@ -43,7 +48,22 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 }
 
 // loadRelays loads relay entries from the remote CSV, using a local cache if it's recent enough.
-func loadRelays() ([]relayEntry, error) {
+// A valid CSV at localPath takes precedence and avoids the network entirely;
+// remote overrides the default download URL.
+func loadRelays(localPath, remote string) ([]relayEntry, error) {
+	if localPath != "" {
+		relays, err := parseCSV(localPath)
+		if err == nil && len(relays) > 0 {
+			return relays, nil
+		}
+		badLocalOnce.Do(func() {
+			log.Printf("Ignoring geo relays file %s (unreadable or empty), using the remote list", localPath)
+		})
+	}
+	if remote == "" {
+		remote = remoteURL
+	}
+
 	appDir, err := getAppConfigDir()
 	if err != nil {
 		return nil, fmt.Errorf("cannot determine app config dir: %w", err)
@@ -54,7 +74,7 @@ func loadRelays() ([]relayEntry, error) {
 		return parseCSV(cachePath)
 	}
 
-	resp, err := http.Get(remoteURL)
+	resp, err := http.Get(remote)
 	if err != nil {
 		if relays, err2 := parseCSV(cachePath); err2 == nil {
 			return relays, nil
@@ -116,9 +136,10 @@ func parseCSV(path string) ([]relayEntry, error) {
 
 // closestRelays finds the N closest relays to a given geohash.
 // It uses a locally cached CSV file of relays and their locations, refreshing it if it's older than 24 hours.
+// localPath and remote are passed to loadRelays.
 // If it fails to load or parse the relay list, it returns an error.
-func closestRelays(geohashStr string, count int) ([]string, error) {
-	relays, err := loadRelays()
+func closestRelays(geohashStr string, count int, localPath, remote string) ([]string, error) {
+	relays, err := loadRelays(localPath, remote)
 	if err != nil {
 		return nil, fmt.Errorf("could not load geo-relays: %w", err)
 	}
@@ -1,17 +1,55 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbd-wtf/go-nostr"
 )
 
 // User Blocking
 
+func (c *client) handleBlock(payload string) {
+	parts := strings.SplitN(strings.TrimSpace(payload), " ", 2)
+	if len(parts) == 2 {
+		switch parts[0] {
+		case "export":
+			c.exportBlockList(strings.TrimSpace(parts[1]))
+			return
+		case "import":
+			c.importBlockList(strings.TrimSpace(parts[1]))
+			return
+		}
+	}
+	c.blockUser(payload)
+}
+
 func (c *client) blockUser(payload string) {
 	var pkToBlock, nickToBlock string
 
+	if pk, ok := parsePubKey(payload); ok {
+		pkToBlock = pk
+		for _, blockedUser := range c.config.BlockedUsers {
+			if blockedUser.PubKey == pkToBlock {
+				c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("User %s... is already blocked.", pkToBlock[:8])}
+				return
+			}
+		}
+		c.config.BlockedUsers = append(c.config.BlockedUsers, blockedUser{PubKey: pkToBlock})
+		c.saveConfig()
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Blocked user %s... Their messages will now be hidden.", pkToBlock[:8])}
+		return
+	}
+
 	for _, pk := range c.userContext.Keys() {
 		if ctx, ok := c.userContext.Get(pk); ok {
 			userIdentifier := fmt.Sprintf("@%s#%s", ctx.nick, ctx.shortPubKey)
@@ -75,6 +113,59 @@ func (c *client) unblockUser(payload string) {
 	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Unblocked user %s.", unblockedNick)}
 }
 
+// blockListFile is the on-disk format used by /block export and /block import.
+type blockListFile struct {
+	BlockedUsers []blockedUser `json:"blocked_users"`
+}
+
+func (c *client) exportBlockList(path string) {
+	data, err := json.MarshalIndent(blockListFile{BlockedUsers: c.config.BlockedUsers}, "", "  ")
+	if err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not encode block list: %v", err)}
+		return
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not write block list: %v", err)}
+		return
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Exported %d blocked user(s) to %s.", len(c.config.BlockedUsers), path)}
+}
+
+func (c *client) importBlockList(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not read block list: %v", err)}
+		return
+	}
+	var file blockListFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not decode block list: %v", err)}
+		return
+	}
+
+	existing := make(map[string]struct{}, len(c.config.BlockedUsers))
+	for _, u := range c.config.BlockedUsers {
+		existing[u.PubKey] = struct{}{}
+	}
+
+	added, skipped := 0, 0
+	for _, u := range file.BlockedUsers {
+		u.PubKey = strings.ToLower(strings.TrimSpace(u.PubKey))
+		if _, ok := existing[u.PubKey]; ok || !nostr.IsValidPublicKey(u.PubKey) {
+			skipped++
+			continue
+		}
+		existing[u.PubKey] = struct{}{}
+		c.config.BlockedUsers = append(c.config.BlockedUsers, u)
+		added++
+	}
+
+	if added > 0 {
+		c.saveConfig()
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Imported block list from %s: %d added, %d skipped.", path, added, skipped)}
+}
+
 func (c *client) listBlockedUsers() {
 	if len(c.config.BlockedUsers) == 0 {
 		c.eventsChan <- DisplayEvent{Type: "INFO", Content: "Your block list is empty. Use /block <@nick> to block someone."}
@@ -110,11 +201,21 @@ func (c *client) handleFilter(payload string) {
 }
 
 func (c *client) addFilter(p string) {
-	newFilter := filter{Pattern: p, Enabled: true}
+	chat, p := parseScopedPattern(p)
+	field, p := splitPatternField(p)
+	p, flags := splitPatternFlags(p)
+	if p == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /filter [@chat] [nick:]<word[:iw]|/regex/[iw]>"}
+		return
+	}
+	newFilter := filter{Pattern: p, Enabled: true, Chat: chat, Flags: flags, Field: field}
+	if !c.checkPattern(newFilter) {
+		return
+	}
 	c.config.Filters = append(c.config.Filters, newFilter)
 	c.saveConfig()
 	c.rebuildRegexCaches()
-	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Added and enabled filter: " + p}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Added and enabled filter: " + newFilter.label() + scopeSuffix(chat)}
 }
 
 func (c *client) toggleFilter(idx int) {
@@ -133,7 +234,7 @@ func (c *client) toggleFilter(idx int) {
 	if c.config.Filters[filterIndex].Enabled {
 		status = "enabled"
 	}
-	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Filter %d (%s) is now %s.", idx, c.config.Filters[filterIndex].Pattern, status)}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Filter %d (%s) is now %s.", idx, c.config.Filters[filterIndex].label(), status)}
 }
 
 func (c *client) listFilters() {
@@ -150,7 +251,7 @@ func (c *client) listFilters() {
 		} else {
 			statusSymbol = "-"
 		}
-		b.WriteString(fmt.Sprintf("\n[%d] %s %s", i+1, statusSymbol, f.Pattern))
+		b.WriteString(fmt.Sprintf("\n[%d] %s %s%s", i+1, statusSymbol, f.label(), scopeSuffix(f.Chat)))
 	}
 	c.eventsChan <- DisplayEvent{Type: "INFO", Content: b.String()}
 }
@@ -165,7 +266,7 @@ func (c *client) removeFilter(p string) {
 		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Invalid filter number."}
 		return
 	}
-	removed := c.config.Filters[idx-1].Pattern
+	removed := c.config.Filters[idx-1].label()
 	c.config.Filters = append(c.config.Filters[:idx-1], c.config.Filters[idx:]...)
 	c.saveConfig()
 	c.rebuildRegexCaches()
@@ -194,11 +295,25 @@ func (c *client) handleMute(payload string) {
 }
 
 func (c *client) addMute(p string) {
-	newMute := filter{Pattern: p, Enabled: true}
+	chat, p := parseScopedPattern(p)
+	p, duration := splitDurationSuffix(p)
+	field, p := splitPatternField(p)
+	p, flags := splitPatternFlags(p)
+	if p == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /mute [@chat] [nick:]<word[:iw]|/regex/[iw]> [duration]"}
+		return
+	}
+	newMute := filter{Pattern: p, Enabled: true, Chat: chat, Flags: flags, Field: field}
+	if !c.checkPattern(newMute) {
+		return
+	}
+	if duration > 0 {
+		newMute.ExpiresAt = time.Now().Add(duration).Unix()
+	}
 	c.config.Mutes = append(c.config.Mutes, newMute)
 	c.saveConfig()
 	c.rebuildRegexCaches()
-	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Muted and enabled: " + p}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Muted and enabled: " + newMute.label() + scopeSuffix(chat) + expirySuffix(newMute.ExpiresAt)}
 }
 
 func (c *client) toggleMute(idx int) {
@@ -216,7 +331,7 @@ func (c *client) toggleMute(idx int) {
 	if c.config.Mutes[muteIndex].Enabled {
 		status = "enabled"
 	}
-	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Mute %d (%s) is now %s.", idx, c.config.Mutes[muteIndex].Pattern, status)}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Mute %d (%s) is now %s.", idx, c.config.Mutes[muteIndex].label(), status)}
 }
 
 func (c *client) listMutes() {
@@ -233,7 +348,7 @@ func (c *client) listMutes() {
 		} else {
 			statusSymbol = "-"
 		}
-		b.WriteString(fmt.Sprintf("\n[%d] %s %s", i+1, statusSymbol, m.Pattern))
+		b.WriteString(fmt.Sprintf("\n[%d] %s %s%s%s", i+1, statusSymbol, m.label(), scopeSuffix(m.Chat), expirySuffix(m.ExpiresAt)))
 	}
 	c.eventsChan <- DisplayEvent{Type: "INFO", Content: b.String()}
 }
@@ -248,7 +363,7 @@ func (c *client) removeMute(p string) {
 		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Invalid mute number."}
 		return
 	}
-	removed := c.config.Mutes[idx-1].Pattern
+	removed := c.config.Mutes[idx-1].label()
 	c.config.Mutes = append(c.config.Mutes[:idx-1], c.config.Mutes[idx:]...)
 	c.saveConfig()
 	c.rebuildRegexCaches()
@@ -262,45 +377,474 @@ func (c *client) clearMutes() {
 	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Cleared all mutes."}
 }
 
+// Highlight Management
+
+func (c *client) handleHighlight(payload string) {
+	if payload == "" {
+		c.listHighlights()
+		return
+	}
+	if idx, err := strconv.Atoi(payload); err == nil {
+		c.toggleHighlight(idx)
+		return
+	}
+	c.addHighlight(payload)
+}
+
+func (c *client) addHighlight(p string) {
+	chat, p := parseScopedPattern(p)
+	field, p := splitPatternField(p)
+	p, flags := splitPatternFlags(p)
+	if p == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /highlight [@chat] [nick:]<word[:iw]|/regex/[iw]>"}
+		return
+	}
+	newHighlight := filter{Pattern: p, Enabled: true, Chat: chat, Flags: flags, Field: field}
+	if !c.checkPattern(newHighlight) {
+		return
+	}
+	c.config.Highlights = append(c.config.Highlights, newHighlight)
+	c.saveConfig()
+	c.rebuildRegexCaches()
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Added and enabled highlight: " + newHighlight.label() + scopeSuffix(chat)}
+}
+
+func (c *client) toggleHighlight(idx int) {
+	if idx < 1 || idx > len(c.config.Highlights) {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid highlight number: %d. Use '/highlight' to see the list.", idx)}
+		return
+	}
+	h := &c.config.Highlights[idx-1]
+	h.Enabled = !h.Enabled
+	c.saveConfig()
+	c.rebuildRegexCaches()
+
+	status := "disabled"
+	if h.Enabled {
+		status = "enabled"
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Highlight %d (%s) is now %s.", idx, h.label(), status)}
+}
+
+func (c *client) listHighlights() {
+	if len(c.config.Highlights) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: "No highlights set."}
+		return
+	}
+	var b strings.Builder
+	b.WriteString("\nHighlights:")
+	for i, h := range c.config.Highlights {
+		statusSymbol := "-"
+		if h.Enabled {
+			statusSymbol = "+"
+		}
+		b.WriteString(fmt.Sprintf("\n[%d] %s %s%s", i+1, statusSymbol, h.label(), scopeSuffix(h.Chat)))
+	}
+	c.eventsChan <- DisplayEvent{Type: "INFO", Content: b.String()}
+}
+
+func (c *client) removeHighlight(p string) {
+	idx, err := strconv.Atoi(p)
+	if err != nil || idx < 1 || idx > len(c.config.Highlights) {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Invalid highlight number."}
+		return
+	}
+	removed := c.config.Highlights[idx-1].label()
+	c.config.Highlights = append(c.config.Highlights[:idx-1], c.config.Highlights[idx:]...)
+	c.saveConfig()
+	c.rebuildRegexCaches()
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Removed highlight: " + removed}
+}
+
+func (c *client) clearHighlights() {
+	c.config.Highlights = []filter{}
+	c.saveConfig()
+	c.rebuildRegexCaches()
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Cleared all highlights."}
+}
+
+// expireMutes removes temporary mutes whose expiry has passed.
+func (c *client) expireMutes() {
+	now := time.Now().Unix()
+	kept := make([]filter, 0, len(c.config.Mutes))
+	var expired []string
+	for _, m := range c.config.Mutes {
+		if m.ExpiresAt > 0 && m.ExpiresAt <= now {
+			expired = append(expired, m.label())
+			continue
+		}
+		kept = append(kept, m)
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	c.config.Mutes = kept
+	c.saveConfig()
+	c.rebuildRegexCaches()
+	for _, p := range expired {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Mute expired: " + p}
+	}
+}
+
+// runMuteExpiry asks the action loop to expire mutes every muteExpiryInterval.
+func (c *client) runMuteExpiry() {
+	ticker := time.NewTicker(muteExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.post(UserAction{Type: "EXPIRE_MUTES"})
+		}
+	}
+}
+
+// Flood Control
+
+// floodEntry counts copies of one message text seen within the flood window.
+type floodEntry struct {
+	first  time.Time
+	count  int
+	logged bool
+}
+
+// isFlood records content and reports whether it has been seen more than
+// flood_threshold times within flood_window, from any pubkeys. Sustained
+// spam keeps the window open so it stays suppressed.
+func (c *client) isFlood(content, chat string) bool {
+	threshold := c.config.FloodThreshold
+	if threshold <= 0 {
+		return false
+	}
+	key := strings.ToLower(strings.Join(strings.Fields(content), " "))
+	now := time.Now()
+
+	c.floodMu.Lock()
+	e, ok := c.floodCache.Get(key)
+	if !ok || now.Sub(e.first) > c.floodWindow() {
+		e = floodEntry{first: now}
+	}
+	e.count++
+	flooded := e.count > threshold
+	first := flooded && !e.logged
+	if flooded {
+		e.first = now
+		e.logged = true
+	}
+	c.floodCache.Add(key, e)
+	c.floodMu.Unlock()
+
+	if first {
+		log.Printf("Suppressing repeated message in %s after %d copies", chat, threshold)
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Suppressing a message repeated more than %d times in %s.", threshold, chat)}
+	}
+	return flooded
+}
+
+func (c *client) floodWindow() time.Duration {
+	if c.config.FloodWindow > 0 {
+		return time.Duration(c.config.FloodWindow) * time.Second
+	}
+	return defaultFloodWindow * time.Second
+}
+
 // Helpers
 
 func (c *client) rebuildRegexCaches() {
+	now := time.Now().Unix()
 	compileAll := func(src []filter) []compiledPattern {
 		out := make([]compiledPattern, 0, len(src))
 		for _, item := range src {
+			if item.ExpiresAt > 0 && item.ExpiresAt <= now {
+				continue
+			}
 			if item.Enabled {
-				out = append(out, compilePattern(item.Pattern))
+				cp := compilePattern(item.matchPattern(), item.Flags)
+				cp.chat = item.Chat
+				cp.field = item.Field
+				out = append(out, cp)
 			}
 		}
 		return out
 	}
 	c.filtersCompiled = compileAll(c.config.Filters)
 	c.mutesCompiled = compileAll(c.config.Mutes)
+	c.highlightsCompiled = compileAll(c.config.Highlights)
 }
 
-func compilePattern(p string) compiledPattern {
+func compilePattern(p, flags string) compiledPattern {
 	p = strings.TrimSpace(p)
-	if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+	fold := strings.Contains(flags, "i")
+	word := strings.Contains(flags, "w")
+	if isRegexPattern(p) {
 		body := p[1 : len(p)-1]
-		if re, err := regexp.Compile(body); err == nil {
+		if re, err := regexp.Compile(regexExpr(body, flags)); err == nil {
 			return compiledPattern{raw: p, regex: re}
 		}
-		return compiledPattern{raw: p, literal: body}
+		p = body
+	}
+	cp := compiledPattern{raw: p, literal: p, fold: fold, word: word}
+	if fold {
+		cp.literal = strings.ToLower(p)
+	}
+	return cp
+}
+
+// regexExpr applies match flags to the body of a /regex/ pattern.
+func regexExpr(body, flags string) string {
+	if strings.Contains(flags, "w") {
+		body = `\b(?:` + body + `)\b`
+	}
+	if strings.Contains(flags, "i") {
+		body = "(?i)" + body
+	}
+	return body
+}
+
+// checkPattern reports a /regex/ that fails to compile. It returns false if
+// the pattern should not be added: always for nick patterns, which have no
+// literal form, and for any pattern when StrictPatterns is set.
+func (c *client) checkPattern(f filter) bool {
+	p := f.matchPattern()
+	if !isRegexPattern(p) {
+		return true
+	}
+	_, err := regexp.Compile(regexExpr(p[1:len(p)-1], f.Flags))
+	if err == nil {
+		return true
+	}
+	if c.config.StrictPatterns || f.Field == fieldNick {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid regex %s: %v. Not added.", f.label(), err)}
+		return false
+	}
+	c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid regex %s: %v. Matching it as literal text instead.", f.label(), err)}
+	return true
+}
+
+func isRegexPattern(p string) bool {
+	return len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/")
+}
+
+// splitPatternFlags splits trailing match flags from a pattern, either
+// "word:iw" for literals or "/regex/i" for regexes. Flags are returned
+// deduplicated in a fixed order; anything else is left as part of the pattern.
+func splitPatternFlags(p string) (string, string) {
+	var body, suffix string
+	if i := strings.LastIndex(p, "/"); i > 0 && strings.HasPrefix(p, "/") {
+		body, suffix = p[:i+1], p[i+1:]
+	} else if i := strings.LastIndex(p, ":"); i > 0 {
+		body, suffix = p[:i], p[i+1:]
+	} else {
+		return p, ""
+	}
+	if suffix == "" || strings.Trim(suffix, "iw") != "" {
+		return p, ""
+	}
+	var flags string
+	for _, f := range "iw" {
+		if strings.ContainsRune(suffix, f) {
+			flags += string(f)
+		}
+	}
+	return body, flags
+}
+
+// splitPatternField splits a leading "nick:" field selector from a pattern.
+func splitPatternField(p string) (string, string) {
+	if rest, ok := strings.CutPrefix(p, fieldNick+":"); ok {
+		return fieldNick, strings.TrimSpace(rest)
 	}
-	return compiledPattern{raw: p, literal: p}
+	return fieldContent, p
 }
 
-func (c *client) matchesAny(content string, patterns []compiledPattern) bool {
+// matchPattern returns the pattern to compile. Nick patterns are regexes
+// matched against the whole nick.
+func (f filter) matchPattern() string {
+	if f.Field == fieldNick && !isRegexPattern(f.Pattern) {
+		return "/^(?:" + f.Pattern + ")$/"
+	}
+	return f.Pattern
+}
+
+// label renders a filter the way it is typed, including its field and flags.
+func (f filter) label() string {
+	label := f.Pattern
+	switch {
+	case f.Flags == "":
+	case isRegexPattern(f.Pattern):
+		label += f.Flags
+	default:
+		label += ":" + f.Flags
+	}
+	if f.Field != fieldContent {
+		label = f.Field + ":" + label
+	}
+	return label
+}
+
+// parseScopedPattern splits an optional leading "@chat" scope from a pattern.
+func parseScopedPattern(p string) (chat, pattern string) {
+	p = strings.TrimSpace(p)
+	if !strings.HasPrefix(p, "@") {
+		return "", p
+	}
+	parts := strings.SplitN(p, " ", 2)
+	chat = strings.ToLower(strings.TrimPrefix(parts[0], "@"))
+	if len(parts) < 2 {
+		return chat, ""
+	}
+	return chat, strings.TrimSpace(parts[1])
+}
+
+func scopeSuffix(chat string) string {
+	if chat == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (in %s)", chat)
+}
+
+// splitDurationSuffix splits a trailing duration such as "1h" or "30m" from a pattern.
+// Single-word patterns are never treated as a duration.
+func splitDurationSuffix(p string) (string, time.Duration) {
+	fields := strings.Fields(p)
+	if len(fields) < 2 {
+		return p, 0
+	}
+	last := fields[len(fields)-1]
+	d, err := time.ParseDuration(last)
+	if err != nil || d <= 0 {
+		return p, 0
+	}
+	return strings.TrimSpace(strings.TrimSuffix(p, last)), d
+}
+
+func expirySuffix(expiresAt int64) string {
+	if expiresAt == 0 {
+		return ""
+	}
+	left := max(time.Until(time.Unix(expiresAt, 0)).Round(time.Minute), time.Minute)
+	return fmt.Sprintf(" (expires in %s)", strings.TrimSuffix(left.String(), "0s"))
+}
+
+// patternsForChat returns the patterns that apply to the given chat.
+func patternsForChat(patterns []compiledPattern, chat string) []compiledPattern {
+	out := make([]compiledPattern, 0, len(patterns))
+	for _, pat := range patterns {
+		if pat.chat == "" || pat.chat == chat {
+			out = append(out, pat)
+		}
+	}
+	return out
+}
+
+func (c *client) matchesAny(content, nick string, patterns []compiledPattern) bool {
 	for _, pat := range patterns {
+		s := content
+		if pat.field == fieldNick {
+			s = nick
+		}
 		if pat.regex != nil {
-			if pat.regex.MatchString(content) {
+			if pat.regex.MatchString(s) {
 				return true
 			}
 		} else if pat.literal != "" {
-			if strings.Contains(content, pat.literal) {
+			if pat.fold {
+				s = strings.ToLower(s)
+			}
+			if containsLiteral(s, pat.literal, pat.word) {
 				return true
 			}
 		}
 	}
 	return false
 }
+
+// highlightRanges returns the sorted, non-overlapping byte ranges of content
+// matched by the given patterns. A matching nick pattern covers the whole content.
+func (c *client) highlightRanges(content, nick string, patterns []compiledPattern) [][2]int {
+	if content == "" {
+		return nil
+	}
+	var ranges [][2]int
+	for _, pat := range patterns {
+		if pat.field == fieldNick {
+			if c.matchesAny(content, nick, []compiledPattern{pat}) {
+				return [][2]int{{0, len(content)}}
+			}
+			continue
+		}
+		if pat.regex != nil {
+			for _, m := range pat.regex.FindAllStringIndex(content, -1) {
+				if m[1] > m[0] {
+					ranges = append(ranges, [2]int{m[0], m[1]})
+				}
+			}
+		} else if pat.literal != "" {
+			s := content
+			if pat.fold {
+				s = strings.ToLower(content)
+				if len(s) != len(content) {
+					// Lowercasing changed byte offsets; fall back to the whole message.
+					if containsLiteral(s, pat.literal, pat.word) {
+						return [][2]int{{0, len(content)}}
+					}
+					continue
+				}
+			}
+			ranges = append(ranges, literalIndexes(s, pat.literal, pat.word, -1)...)
+		}
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(ranges, func(a, b [2]int) int { return a[0] - b[0] })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1] {
+			last[1] = max(last[1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// containsLiteral reports whether sub occurs in s, optionally only as a whole word.
+func containsLiteral(s, sub string, word bool) bool {
+	if !word {
+		return strings.Contains(s, sub)
+	}
+	return len(literalIndexes(s, sub, word, 1)) > 0
+}
+
+// literalIndexes returns the byte ranges of up to n occurrences of sub in s
+// (all of them if n < 0), optionally only those delimited as whole words.
+func literalIndexes(s, sub string, word bool, n int) [][2]int {
+	var out [][2]int
+	for offset := 0; offset <= len(s)-len(sub) && n != 0; {
+		i := strings.Index(s[offset:], sub)
+		if i < 0 {
+			break
+		}
+		start, end := offset+i, offset+i+len(sub)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !word || ((start == 0 || !isWordRune(before)) && (end == len(s) || !isWordRune(after))) {
+			out = append(out, [2]int{start, end})
+			n--
+			offset = end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		offset = start + size
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
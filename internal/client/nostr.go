@@ -19,25 +19,43 @@ import (
 
 // Subscription & Relay Lifecycle
 
+// getRelayPoolForChat returns the relays to subscribe to for chat. Write-only
+// anchors are left out; see publishPoolForChat.
 func (c *client) getRelayPoolForChat(chat string) []string {
+	return c.relayPoolForChat(chat, anchorRead)
+}
+
+// publishPoolForChat returns the relays to publish chat messages to, which
+// leaves out read-only anchors.
+func (c *client) publishPoolForChat(chat string) []string {
+	return c.relayPoolForChat(chat, anchorWrite)
+}
+
+func (c *client) relayPoolForChat(chat, intent string) []string {
 	relaySet := make(map[string]struct{})
 
-	for _, url := range c.config.AnchorRelays {
+	for _, url := range c.anchorsFor(intent) {
 		relaySet[url] = struct{}{}
 	}
 
-	if c.discoveredStore != nil {
+	if c.discoveredStore != nil && !c.config.DisableDiscovery {
 		for _, url := range c.getDiscoveredRelayURLs() {
 			relaySet[url] = struct{}{}
 		}
 	}
 
-	if geohash.Validate(chat) == nil {
-		closest, err := closestRelays(chat, defaultRelayCount)
+	if geohash.Validate(chat) == nil && !c.config.DisableGeoRelays {
+		closest, err := closestRelays(chat, c.geoRelayCount(), c.config.GeoRelaysPath, c.config.GeoRelaysURL)
 		if err == nil {
+			c.geoRelaysMu.Lock()
 			for _, url := range closest {
 				relaySet[url] = struct{}{}
+				if c.geoRelays[url] == nil {
+					c.geoRelays[url] = make(map[string]struct{})
+				}
+				c.geoRelays[url][chat] = struct{}{}
 			}
+			c.geoRelaysMu.Unlock()
 		}
 	}
 
@@ -53,19 +71,52 @@ func (c *client) getRelayPoolForChat(chat string) []string {
 	return relayURLs
 }
 
-func (c *client) updateAllSubscriptions() {
+// forgetGeoRelays drops chat from the chats picking each geo relay, and the
+// relays no chat picks anymore.
+func (c *client) forgetGeoRelays(chat string) {
+	c.geoRelaysMu.Lock()
+	defer c.geoRelaysMu.Unlock()
+	for url, chats := range c.geoRelays {
+		delete(chats, chat)
+		if len(chats) == 0 {
+			delete(c.geoRelays, url)
+		}
+	}
+}
+
+// subscribedChats returns the set of chats that should have live subscriptions.
+// With SubscribeAllJoined every joined chat is included, which keeps background
+// chats live at the cost of connecting to the union of all their relay pools.
+func (c *client) subscribedChats() map[string]struct{} {
 	activeView := c.getActiveView()
 
-	activeChats := make(map[string]struct{})
+	chats := make(map[string]struct{})
+	if c.config.SubscribeAllJoined {
+		for _, v := range c.config.Views {
+			if !v.IsGroup && v.DM == "" && v.Name != "" {
+				chats[v.Name] = struct{}{}
+			}
+		}
+	}
 	if activeView != nil {
 		if activeView.IsGroup {
 			for _, child := range activeView.Children {
-				activeChats[child] = struct{}{}
+				if !strings.HasPrefix(child, dmViewPrefix) {
+					chats[child] = struct{}{}
+				}
 			}
-		} else if activeView.Name != "" {
-			activeChats[activeView.Name] = struct{}{}
+		} else if activeView.DM == "" && activeView.Name != "" {
+			chats[activeView.Name] = struct{}{}
 		}
 	}
+	if c.config.DMs {
+		chats[dmInboxChat] = struct{}{}
+	}
+	return chats
+}
+
+func (c *client) updateAllSubscriptions() {
+	activeChats := c.subscribedChats()
 
 	if len(activeChats) == 0 {
 		c.updateRelaySubscriptions(make(map[string][]string))
@@ -73,7 +124,11 @@ func (c *client) updateAllSubscriptions() {
 		return
 	}
 
-	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Updating subscriptions for active chat/group..."}
+	if c.config.SubscribeAllJoined {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Updating subscriptions for all joined chats..."}
+	} else {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Updating subscriptions for active chat/group..."}
+	}
 
 	desiredRelayToChats := make(map[string][]string)
 	for chat := range activeChats {
@@ -86,7 +141,18 @@ func (c *client) updateAllSubscriptions() {
 		}
 	}
 
+	// Write-only anchors stay connected for publishing, without a subscription.
+	for _, url := range c.config.AnchorRelays {
+		if _, ok := desiredRelayToChats[url]; !ok && c.anchorIntent(url) == anchorWrite {
+			desiredRelayToChats[url] = nil
+		}
+	}
+
 	c.updateRelaySubscriptions(desiredRelayToChats)
+
+	if c.config.AutoPoW {
+		go c.applyAutoPoW(activeChats)
+	}
 }
 
 func (c *client) updateRelaySubscriptions(desiredRelays map[string][]string) {
@@ -95,6 +161,8 @@ func (c *client) updateRelaySubscriptions(desiredRelays map[string][]string) {
 	maps.Copy(currentRelays, c.relays)
 	c.relaysMu.Unlock()
 
+	c.capRelays(desiredRelays, currentRelays)
+
 	var wg sync.WaitGroup
 	for url, chats := range desiredRelays {
 
@@ -103,6 +171,15 @@ func (c *client) updateRelaySubscriptions(desiredRelays map[string][]string) {
 		}
 
 		if mr, exists := currentRelays[url]; exists {
+			if mr.writeOnly != (len(chats) == 0) {
+				// A write-only anchor is watched instead of listened to, so a
+				// relay switching between the two is reopened.
+				go func(url string, chats []string) {
+					c.closeRelay(url)
+					c.manageRelayConnection(url, chats)
+				}(url, chats)
+				continue
+			}
 			wg.Add(1)
 			go func(mr *managedRelay, chats []string) {
 				defer wg.Done()
@@ -142,8 +219,72 @@ func (c *client) updateRelaySubscriptions(desiredRelays map[string][]string) {
 	c.sendRelaysUpdate()
 }
 
+// capRelays removes the slowest discovered relays from desiredRelays until it
+// fits MaxConnectedRelays. Anchor and geo relays are never shed, and relays
+// that are not connected yet count as slower than any connected one. The cap
+// is reported only when the kept relays or the number of candidates change.
+func (c *client) capRelays(desiredRelays map[string][]string, currentRelays map[string]*managedRelay) {
+	limit := c.config.MaxConnectedRelays
+	if limit <= 0 || len(desiredRelays) <= limit {
+		c.relaysMu.Lock()
+		c.lastRelayCap = ""
+		c.relaysMu.Unlock()
+		return
+	}
+
+	anchors := make(map[string]struct{}, len(c.config.AnchorRelays))
+	for _, a := range c.config.AnchorRelays {
+		if na, err := normalizeRelayURL(a); err == nil {
+			anchors[na] = struct{}{}
+		}
+	}
+
+	var sheddable []string
+	for url := range desiredRelays {
+		if _, isAnchor := anchors[url]; isAnchor || !c.isDiscoveredRelay(url) {
+			continue
+		}
+		sheddable = append(sheddable, url)
+	}
+
+	latency := func(url string) time.Duration {
+		if mr, ok := currentRelays[url]; ok {
+			mr.mu.Lock()
+			defer mr.mu.Unlock()
+			return mr.latency
+		}
+		return time.Duration(math.MaxInt64)
+	}
+	sort.Slice(sheddable, func(i, j int) bool {
+		return latency(sheddable[i]) > latency(sheddable[j])
+	})
+
+	candidates := len(desiredRelays)
+	for _, url := range sheddable {
+		if len(desiredRelays) <= limit {
+			break
+		}
+		delete(desiredRelays, url)
+	}
+
+	kept := slices.Sorted(maps.Keys(desiredRelays))
+	summary := fmt.Sprintf("%d:%s", candidates, strings.Join(kept, " "))
+	c.relaysMu.Lock()
+	changed := summary != c.lastRelayCap
+	c.lastRelayCap = summary
+	c.relaysMu.Unlock()
+	if !changed {
+		return
+	}
+
+	c.eventsChan <- DisplayEvent{
+		Type:    "STATUS",
+		Content: fmt.Sprintf("Relay cap: using %d of %d relays (max %d).", len(desiredRelays), candidates, limit),
+	}
+}
+
 func (c *client) manageRelayConnection(url string, chats []string) {
-	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.ctx, c.connectTimeout())
 	defer cancel()
 
 	if c.relayFailed(url) {
@@ -175,6 +316,7 @@ func (c *client) manageRelayConnection(url string, chats []string) {
 		latency:           latency,
 		connected:         true,
 		reconnectAttempts: 0,
+		writeOnly:         len(chats) == 0,
 	}
 
 	c.relaysMu.Lock()
@@ -200,12 +342,176 @@ func (c *client) manageRelayConnection(url string, chats []string) {
 		return
 	}
 
+	if mr.writeOnly {
+		c.wg.Go(func() {
+			c.watchWriteOnlyRelay(mr)
+		})
+	} else {
+		c.wg.Go(func() {
+			c.listenForEvents(mr)
+		})
+	}
+	if interval := c.pingInterval(); interval > 0 {
+		c.wg.Go(func() {
+			c.pingRelay(mr, interval)
+		})
+	}
 	c.wg.Go(func() {
-		c.listenForEvents(mr)
+		c.prefetchRelayInfo(url)
 	})
 }
 
+// closeRelay removes a relay from the pool, ends its subscription and closes
+// the connection. It returns the chats the relay was serving.
+func (c *client) closeRelay(url string) ([]string, bool) {
+	c.relaysMu.Lock()
+	mr, ok := c.relays[url]
+	if ok {
+		delete(c.relays, url)
+	}
+	c.relaysMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	mr.mu.Lock()
+	sub := mr.subscription
+	chats := mrCurrentChatsLocked(sub)
+	mr.subscription = nil
+	mr.connected = false
+	mr.mu.Unlock()
+
+	if sub != nil {
+		sub.Unsub()
+	}
+	mr.relay.Close()
+	c.sendRelaysUpdate()
+	return chats, true
+}
+
+// dropRelay disconnects a relay and puts it in the fail cache.
+func (c *client) dropRelay(payload string) {
+	url, ok := c.resolveConnectedRelay(strings.TrimSpace(payload))
+	if !ok {
+		return
+	}
+	if _, ok := c.closeRelay(url); !ok {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Not connected to %s.", url)}
+		return
+	}
+	if norm, err := normalizeRelayURL(url); err == nil {
+		c.failCacheAdd(norm)
+	}
+
+	if c.isDiscoveredRelay(url) {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Dropped relay %s.", url)}
+	} else {
+		c.eventsChan <- DisplayEvent{
+			Type:    "STATUS",
+			Content: fmt.Sprintf("Dropped relay %s. It is an anchor or geo relay and comes back on the next subscription update.", url),
+		}
+	}
+}
+
+// reconnectRelay clears a relay from the fail cache and opens a fresh connection
+// serving the same chats.
+func (c *client) reconnectRelay(payload string) {
+	url, ok := c.resolveConnectedRelay(strings.TrimSpace(payload))
+	if !ok {
+		return
+	}
+	if norm, err := normalizeRelayURL(url); err == nil && c.verifyFailCache != nil {
+		c.verifyFailCache.Remove(norm)
+	}
+
+	chats, ok := c.closeRelay(url)
+	if !ok || len(chats) == 0 {
+		// Not connected (e.g. dropped earlier): serve whatever chats want it now.
+		for chat := range c.subscribedChats() {
+			if slices.Contains(c.getRelayPoolForChat(chat), url) {
+				chats = append(chats, chat)
+			}
+		}
+	}
+	if len(chats) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("No active chat uses %s.", url)}
+		return
+	}
+
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Reconnecting to %s...", url)}
+	c.manageRelayConnection(url, chats)
+
+	c.relaysMu.Lock()
+	_, connected := c.relays[url]
+	c.relaysMu.Unlock()
+	if connected {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Reconnected to %s.", url)}
+	}
+}
+
+// pingRelay periodically times a cheap REQ on the relay to keep its latency
+// and connection state current. It stops when the relay is dropped.
+func (c *client) pingRelay(mr *managedRelay, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.relaysMu.Lock()
+		current := c.relays[mr.url] == mr
+		c.relaysMu.Unlock()
+		if !current {
+			return
+		}
+
+		latency, err := c.measureLatency(mr.relay)
+
+		mr.mu.Lock()
+		if err == nil {
+			mr.latency = latency
+			if mr.subscription != nil {
+				mr.lastAlive = max(mr.lastAlive, nostr.Now())
+			}
+		}
+		mr.connected = err == nil
+		mr.mu.Unlock()
+
+		c.sendRelaysUpdate()
+	}
+}
+
+// measureLatency times a REQ with limit 0 until the relay answers with EOSE.
+func (c *client) measureLatency(relay *nostr.Relay) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, pingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	sub, err := relay.Subscribe(ctx, nostr.Filters{{Kinds: []int{ephChatKind}, LimitZero: true}})
+	if err != nil {
+		return 0, err
+	}
+	defer sub.Unsub()
+
+	select {
+	case <-sub.EndOfStoredEvents:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
 func (c *client) replaceSubscription(mr *managedRelay, chats []string) (bool, error) {
+	return c.replaceSubscriptionSince(mr, chats, nostr.Now())
+}
+
+// replaceSubscriptionSince subscribes mr to chats, asking for chat events
+// from since onwards.
+func (c *client) replaceSubscriptionSince(mr *managedRelay, chats []string, since nostr.Timestamp) (bool, error) {
 	mr.mu.Lock()
 	oldChats := mrCurrentChatsLocked(mr.subscription)
 	mr.mu.Unlock()
@@ -213,24 +519,30 @@ func (c *client) replaceSubscription(mr *managedRelay, chats []string) (bool, er
 	if sameStringSet(oldChats, chats) {
 		return false, nil
 	}
+	if len(chats) == 0 {
+		mr.mu.Lock()
+		oldSub := mr.subscription
+		mr.subscription = nil
+		mr.mu.Unlock()
+		if oldSub != nil {
+			oldSub.Unsub()
+		}
+		c.sendRelaysUpdate()
+		return true, nil
+	}
 
-	now := nostr.Now()
 	filters := make(nostr.Filters, 0, len(chats))
 	for _, ch := range chats {
-		since := now
-		if geohash.Validate(ch) == nil {
-			filters = append(filters, nostr.Filter{
-				Kinds: []int{geoChatKind},
-				Tags:  nostr.TagMap{"g": []string{ch}},
-				Since: &since,
-			})
-		} else {
-			filters = append(filters, nostr.Filter{
-				Kinds: []int{ephChatKind},
-				Tags:  nostr.TagMap{"d": []string{ch}},
-				Since: &since,
-			})
+		if ch == dmInboxChat {
+			f := c.dmFilter()
+			f.Limit = c.config.SubscriptionLimit
+			filters = append(filters, f)
+			continue
 		}
+		f := chatFilter(ch)
+		f.Since = &since
+		f.Limit = c.config.SubscriptionLimit
+		filters = append(filters, f)
 	}
 
 	newSub, err := mr.relay.Subscribe(c.ctx, filters)
@@ -241,6 +553,8 @@ func (c *client) replaceSubscription(mr *managedRelay, chats []string) (bool, er
 	mr.mu.Lock()
 	oldSub := mr.subscription
 	mr.subscription = newSub
+	mr.receivedEOSE = false
+	mr.lastAlive = since
 	mr.mu.Unlock()
 
 	if oldSub != nil {
@@ -261,18 +575,103 @@ func (c *client) sendRelaysUpdate() {
 		mr.mu.Lock()
 		connected := mr.connected
 		latency := mr.latency
+		chatCount := len(mrCurrentChatsLocked(mr.subscription))
+		receivedEOSE := mr.receivedEOSE
 		mr.mu.Unlock()
 
 		statuses = append(statuses, RelayInfo{
-			URL:       mr.url,
-			Latency:   latency,
-			Connected: connected,
+			URL:          mr.url,
+			Latency:      latency,
+			Connected:    connected,
+			ChatCount:    chatCount,
+			ReceivedEOSE: receivedEOSE,
+			Type:         c.relayType(mr.url),
 		})
 	}
 
 	c.eventsChan <- DisplayEvent{Type: "RELAYS_UPDATE", Payload: statuses}
 }
 
+// relayType tells where a relay of a chat pool came from. A relay in
+// several places counts as an anchor first, then as a geo relay.
+func (c *client) relayType(url string) string {
+	if slices.Contains(c.config.AnchorRelays, url) {
+		return RelayAnchor
+	}
+	c.geoRelaysMu.Lock()
+	_, geo := c.geoRelays[url]
+	c.geoRelaysMu.Unlock()
+	if geo {
+		return RelayGeo
+	}
+	if s := c.discoveredStore; s != nil {
+		s.mu.RLock()
+		_, ok := s.Relays[url]
+		s.mu.RUnlock()
+		if ok {
+			return RelayDiscovered
+		}
+	}
+	return RelayDefault
+}
+
+// watchWriteOnlyRelay waits for the connection of a write-only anchor to
+// drop and reconnects it. Without a subscription there is nothing to listen
+// to, so the relay's connection context is the only sign that it is gone.
+func (c *client) watchWriteOnlyRelay(mr *managedRelay) {
+	const maxReconnectAttempts = 3
+
+	select {
+	case <-c.ctx.Done():
+		return
+	case <-mr.relay.Context().Done():
+	}
+
+	c.relaysMu.Lock()
+	current := c.relays[mr.url] == mr
+	if current {
+		delete(c.relays, mr.url)
+	}
+	c.relaysMu.Unlock()
+	if !current {
+		// Closed on purpose.
+		return
+	}
+	mr.mu.Lock()
+	mr.connected = false
+	mr.mu.Unlock()
+	c.sendRelaysUpdate()
+
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		err := retryWithBackoff(c.ctx, func() error {
+			c.relaysMu.Lock()
+			_, connected := c.relays[mr.url]
+			c.relaysMu.Unlock()
+			if !connected {
+				c.manageRelayConnection(mr.url, nil)
+				c.relaysMu.Lock()
+				_, connected = c.relays[mr.url]
+				c.relaysMu.Unlock()
+			}
+			if !connected {
+				return fmt.Errorf("could not connect to %s", mr.url)
+			}
+			return nil
+		}, attempt)
+		if err == nil || c.ctx.Err() != nil {
+			return
+		}
+		if !slices.Contains(c.config.AnchorRelays, mr.url) {
+			return
+		}
+	}
+
+	c.eventsChan <- DisplayEvent{
+		Type:    "ERROR",
+		Content: fmt.Sprintf("Write-only anchor %s failed to reconnect after %d attempts. Giving up.", mr.url, maxReconnectAttempts),
+	}
+}
+
 // Event Ingestion & Processing
 
 func (c *client) listenForEvents(mr *managedRelay) {
@@ -288,6 +687,12 @@ func (c *client) listenForEvents(mr *managedRelay) {
 		mr.mu.Unlock()
 
 		if sub == nil {
+			c.relaysMu.Lock()
+			current := c.relays[mr.url] == mr
+			c.relaysMu.Unlock()
+			if !current {
+				return
+			}
 			time.Sleep(200 * time.Millisecond)
 			continue
 		}
@@ -296,6 +701,17 @@ func (c *client) listenForEvents(mr *managedRelay) {
 		case <-c.ctx.Done():
 			return
 
+		case <-sub.EndOfStoredEvents:
+			mr.mu.Lock()
+			current := mr.subscription == sub
+			if current {
+				mr.receivedEOSE = true
+			}
+			mr.mu.Unlock()
+			if current {
+				c.sendRelaysUpdate()
+			}
+
 		case ev, ok := <-sub.Events:
 			if !ok {
 				oldChats := mrCurrentChatsLocked(sub)
@@ -348,8 +764,14 @@ func (c *client) listenForEvents(mr *managedRelay) {
 					return
 				}
 
+				// Resubscribe from when the old subscription was last known
+				// live, so messages sent during the outage are backfilled.
+				// seenCache drops the overlap.
+				mr.mu.Lock()
+				since := mr.lastAlive
+				mr.mu.Unlock()
 				err := retryWithBackoff(c.ctx, func() error {
-					_, err := c.replaceSubscription(mr, oldChats)
+					_, err := c.replaceSubscriptionSince(mr, oldChats, since)
 					return err
 				}, attempts)
 
@@ -376,14 +798,24 @@ func (c *client) listenForEvents(mr *managedRelay) {
 			if ev == nil {
 				continue
 			}
-			c.processEvent(ev, mr.url)
+			mr.mu.Lock()
+			mr.lastAlive = max(mr.lastAlive, min(ev.CreatedAt, nostr.Now()))
+			live := mr.receivedEOSE
+			mr.mu.Unlock()
+			c.processEvent(ev, mr.url, live)
 		}
 	}
 }
 
-func (c *client) processEvent(ev *nostr.Event, relayURL string) {
+// processEvent filters an event from relayURL and queues it for display.
+// live is set for events a subscription delivers after EOSE; stored events
+// (history, backfill after a resubscribe) are old by design and are never
+// marked as clock skewed.
+func (c *client) processEvent(ev *nostr.Event, relayURL string, live bool) {
+	c.stats.received.Add(1)
 	for _, blockedUser := range c.config.BlockedUsers {
 		if ev.PubKey == blockedUser.PubKey {
+			c.stats.droppedBlock.Add(1)
 			return
 		}
 	}
@@ -393,9 +825,16 @@ func (c *client) processEvent(ev *nostr.Event, relayURL string) {
 		c.seenCacheMu.Unlock()
 		return
 	}
-	c.seenCache.Add(ev.ID, true)
+	c.seenCache.Add(ev.ID, eventSeen)
 	c.seenCacheMu.Unlock()
 
+	if ev.Kind == nostr.KindGiftWrap {
+		if c.config.DMs {
+			c.processGiftWrap(ev)
+		}
+		return
+	}
+
 	var eventChat string
 	if gTag := ev.Tags.Find("g"); len(gTag) > 1 {
 		eventChat = gTag[1]
@@ -407,6 +846,7 @@ func (c *client) processEvent(ev *nostr.Event, relayURL string) {
 		return
 	}
 
+	requiredPoW := 0
 	activeView := c.getActiveView()
 	if activeView != nil {
 		isRelevantToActiveView := false
@@ -421,45 +861,69 @@ func (c *client) processEvent(ev *nostr.Event, relayURL string) {
 		}
 
 		if isRelevantToActiveView {
-			requiredPoW := c.effectivePoWForChat(eventChat)
-			if !isPoWValid(ev, requiredPoW) {
-				log.Printf("Dropped event %s from %s for failing PoW check (required: %d)", safeSuffix(ev.ID, 4), eventChat, requiredPoW)
-				return
-			}
+			requiredPoW = c.effectivePoWForChat(eventChat)
 		}
 	}
+	// Senders we have not seen recently must meet first_seen_pow, so
+	// drive-by keys pay more than established participants.
+	if c.config.FirstSeenPoW > 0 && ev.PubKey != c.pk && !c.userContext.Contains(ev.PubKey) {
+		requiredPoW = max(requiredPoW, c.config.FirstSeenPoW)
+	}
 
 	streamKey := "chat:" + eventChat
 	if av := c.getActiveView(); av != nil && av.IsGroup && slices.Contains(av.Children, eventChat) {
 		streamKey = "group:" + av.Name
 	}
 
-	content := truncateString(ev.Content, MaxMsgLen)
-	content = sanitizeString(content)
-
-	if c.matchesAny(content, c.mutesCompiled) {
+	if !isPoWValid(ev, requiredPoW) {
+		c.stats.droppedPoW.Add(1)
+		log.Printf("Dropped event %s from %s for failing PoW check (required: %d)", safeSuffix(ev.ID, 4), eventChat, requiredPoW)
+		c.showDropped(ev, eventChat, streamKey, relayURL, fmt.Sprintf("PoW below %d", requiredPoW))
 		return
 	}
-	if len(c.filtersCompiled) > 0 && !c.matchesAny(content, c.filtersCompiled) {
+
+	content := sanitizeString(ev.Content)
+	if strings.TrimSpace(content) == "" {
 		return
 	}
 
-	nick := npubToTokiPona(ev.PubKey)
-	spk := ev.PubKey[:4]
-	if nickTag := ev.Tags.Find("n"); len(nickTag) > 1 {
-		if s := sanitizeString(nickTag[1]); s != "" {
-			nick = s
-		}
-		spk = safeSuffix(ev.PubKey, 4)
+	nick, spk := c.eventNick(ev)
+
+	if c.matchesAny(content, nick, patternsForChat(c.mutesCompiled, eventChat)) {
+		c.stats.droppedMute.Add(1)
+		c.showDropped(ev, eventChat, streamKey, relayURL, "muted")
+		return
+	}
+	if filters := patternsForChat(c.filtersCompiled, eventChat); len(filters) > 0 && !c.matchesAny(content, nick, filters) {
+		c.stats.droppedFilter.Add(1)
+		c.showDropped(ev, eventChat, streamKey, relayURL, "no filter match")
+		return
+	}
+	if ev.PubKey != c.pk && c.isFlood(content, eventChat) {
+		c.stats.droppedFlood.Add(1)
+		c.showDropped(ev, eventChat, streamKey, relayURL, "flood")
+		return
 	}
 
+	spk = c.disambiguate(ev.PubKey, nick, spk)
+	seen := time.Unix(int64(min(ev.CreatedAt, nostr.Now())), 0)
+	if prev, ok := c.userContext.Peek(ev.PubKey); ok && prev.lastSeen.After(seen) {
+		seen = prev.lastSeen
+	}
 	c.userContext.Add(ev.PubKey, userContext{
 		nick:        nick,
 		chat:        eventChat,
 		shortPubKey: spk,
+		lastSeen:    seen,
 	})
 
-	timestamp := time.Unix(int64(ev.CreatedAt), 0).Format("15:04:05")
+	timestamp := time.Unix(int64(ev.CreatedAt), 0).Format(c.timestampFormat())
+	c.logMessage(ev, eventChat, nick, spk, content)
+
+	var skew int64
+	if d := int64(ev.CreatedAt) - int64(nostr.Now()); live && (d > c.maxClockSkew() || -d > c.maxClockSkew()) {
+		skew = d
+	}
 
 	isOwn := false
 
@@ -474,6 +938,11 @@ func (c *client) processEvent(ev *nostr.Event, relayURL string) {
 		}
 	}
 
+	display, raw := c.displayContent(content, ev.Tags)
+	root, parent := replyTarget(ev.Tags)
+	c.rememberEvent(ev, eventChat, root)
+
+	c.stats.displayed.Add(1)
 	c.enqueueOrdered(streamKey, DisplayEvent{
 		Type:         "NEW_MESSAGE",
 		Timestamp:    timestamp,
@@ -481,55 +950,253 @@ func (c *client) processEvent(ev *nostr.Event, relayURL string) {
 		FullPubKey:   ev.PubKey,
 		ShortPubKey:  spk,
 		IsOwnMessage: isOwn,
-		Content:      content,
+		Content:      display,
+		RawContent:   raw,
 		ID:           safeSuffix(ev.ID, 4),
+		FullID:       ev.ID,
 		Chat:         eventChat,
 		RelayURL:     relayURL,
+		Skew:         skew,
+		ReplyTo:      parent,
+		Highlights:   c.highlightRanges(display, nick, patternsForChat(c.highlightsCompiled, eventChat)),
 	}, int64(ev.CreatedAt), ev.ID)
 }
 
-func (c *client) enqueueOrdered(streamKey string, de DisplayEvent, createdAt int64, id string) {
-	c.orderMu.Lock()
-	if len(c.orderBuf[streamKey]) >= perStreamBufferMax {
-		c.orderBuf[streamKey] = c.orderBuf[streamKey][1:]
+// showDropped shows a message processEvent dropped, marked with the reason,
+// while debug mode is on.
+func (c *client) showDropped(ev *nostr.Event, chat, streamKey, relayURL, reason string) {
+	if !c.debug.Load() {
+		return
 	}
-	c.orderBuf[streamKey] = append(c.orderBuf[streamKey], orderItem{ev: de, createdAt: createdAt, id: id})
-	if _, ok := c.orderTimers[streamKey]; !ok {
-		c.orderTimers[streamKey] = time.AfterFunc(orderingFlushDelay, func() { c.flushOrdered(streamKey) })
+	content := sanitizeString(ev.Content)
+	if strings.TrimSpace(content) == "" {
+		return
 	}
-	c.orderMu.Unlock()
+	nick, spk := c.eventNick(ev)
+	display, raw := c.displayContent(content, ev.Tags)
+	c.enqueueOrdered(streamKey, DisplayEvent{
+		Type:        "NEW_MESSAGE",
+		Timestamp:   time.Unix(int64(ev.CreatedAt), 0).Format(c.timestampFormat()),
+		Nick:        nick,
+		FullPubKey:  ev.PubKey,
+		ShortPubKey: spk,
+		Content:     display,
+		RawContent:  raw,
+		ID:          safeSuffix(ev.ID, 4),
+		FullID:      ev.ID,
+		Chat:        chat,
+		RelayURL:    relayURL,
+		Dropped:     reason,
+	}, int64(ev.CreatedAt), ev.ID)
 }
 
-func (c *client) flushOrdered(streamKey string) {
-	c.orderMu.Lock()
-	buf := c.orderBuf[streamKey]
-	delete(c.orderBuf, streamKey)
-	delete(c.orderTimers, streamKey)
-	c.orderMu.Unlock()
+// loadHistory fetches stored events for the active chat/group and feeds them
+// through processEvent, so mutes, filters, PoW and deduplication all apply.
+func (c *client) loadHistory(payload string) {
+	limit := defaultHistoryLimit
+	if p := strings.TrimSpace(payload); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid history count: '%s'. Must be a positive number.", p)}
+			return
+		}
+		limit = min(n, perStreamBufferMax)
+	}
 
-	if len(buf) == 0 {
+	activeView := c.getActiveView()
+	if activeView == nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "No active chat/group to load history for."}
+		return
+	}
+	if activeView.DM != "" {
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: "DMs from the last two days are loaded automatically."}
 		return
 	}
+	chats := []string{activeView.Name}
+	if activeView.IsGroup {
+		chats = activeView.Children
+	}
 
-	sort.Slice(buf, func(i, j int) bool {
-		if buf[i].createdAt == buf[j].createdAt {
-			return buf[i].id < buf[j].id
+	filters := make(nostr.Filters, 0, len(chats))
+	relayPoolSet := make(map[string]struct{})
+	for _, ch := range chats {
+		f := chatFilter(ch)
+		f.Limit = limit
+		filters = append(filters, f)
+		for _, url := range c.getRelayPoolForChat(ch) {
+			relayPoolSet[url] = struct{}{}
 		}
-		return buf[i].createdAt < buf[j].createdAt
-	})
+	}
+
+	c.relaysMu.Lock()
+	var relays []*managedRelay
+	for url, r := range c.relays {
+		if _, ok := relayPoolSet[url]; !ok || c.relayFailed(url) {
+			continue
+		}
+		relays = append(relays, r)
+	}
+	c.relaysMu.Unlock()
+
+	if len(relays) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Not connected to any suitable relays for %s", activeView.Name)}
+		return
+	}
+
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Loading up to %d past messages for %s...", limit, activeView.Name)}
+
+	type historyItem struct {
+		ev       *nostr.Event
+		relayURL string
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		items []historyItem
+		seen  = make(map[string]struct{})
+	)
+	for _, r := range relays {
+		wg.Add(1)
+		go func(r *managedRelay) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.ctx, historyTimeout)
+			defer cancel()
+
+			sub, err := r.relay.Subscribe(ctx, filters)
+			if err != nil {
+				return
+			}
+			defer sub.Unsub()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-sub.EndOfStoredEvents:
+					return
+				case ev, ok := <-sub.Events:
+					if !ok {
+						return
+					}
+					if ev == nil {
+						continue
+					}
+					mu.Lock()
+					if _, dup := seen[ev.ID]; !dup {
+						seen[ev.ID] = struct{}{}
+						items = append(items, historyItem{ev: ev, relayURL: r.url})
+					}
+					mu.Unlock()
+				}
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].ev.CreatedAt == items[j].ev.CreatedAt {
+			return items[i].ev.ID < items[j].ev.ID
+		}
+		return items[i].ev.CreatedAt < items[j].ev.CreatedAt
+	})
+
+	for _, it := range items {
+		c.processEvent(it.ev, it.relayURL, false)
+	}
+
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Fetched %d stored events for %s.", len(items), activeView.Name)}
+}
+
+// enqueueOrdered buffers an event for display in the stream's next flush.
+// An event already waiting in any buffer (its stream key can change, e.g.
+// when switching between a chat and a group containing it) or already shown
+// is skipped.
+func (c *client) enqueueOrdered(streamKey string, de DisplayEvent, createdAt int64, id string) {
+	c.seenCacheMu.Lock()
+	if state, _ := c.seenCache.Peek(id); state != eventSeen {
+		c.seenCacheMu.Unlock()
+		return
+	}
+	c.seenCache.Add(id, eventQueued)
+	c.seenCacheMu.Unlock()
+
+	c.orderMu.Lock()
+	if len(c.orderBuf[streamKey]) >= perStreamBufferMax {
+		dropped := c.orderBuf[streamKey][0]
+		c.orderBuf[streamKey] = c.orderBuf[streamKey][1:]
+		c.seenCacheMu.Lock()
+		c.seenCache.Add(dropped.id, eventSeen)
+		c.seenCacheMu.Unlock()
+	}
+	c.orderBuf[streamKey] = append(c.orderBuf[streamKey], orderItem{ev: de, createdAt: createdAt, id: id})
+	if _, ok := c.orderTimers[streamKey]; !ok {
+		c.orderTimers[streamKey] = time.AfterFunc(c.orderingDelay(), func() { c.flushOrdered(streamKey) })
+	}
+	c.orderMu.Unlock()
+}
+
+func (c *client) flushOrdered(streamKey string) {
+	c.orderMu.Lock()
+	buf := c.orderBuf[streamKey]
+	delete(c.orderBuf, streamKey)
+	delete(c.orderTimers, streamKey)
+	c.orderMu.Unlock()
+
+	if len(buf) == 0 {
+		return
+	}
+
+	sort.Slice(buf, func(i, j int) bool {
+		if buf[i].createdAt == buf[j].createdAt {
+			return buf[i].id < buf[j].id
+		}
+		return buf[i].createdAt < buf[j].createdAt
+	})
 
 	for _, it := range buf {
+		if c.wasFlushed(it.id) {
+			continue
+		}
 		select {
 		case c.eventsChan <- it.ev:
+			c.seenCacheMu.Lock()
+			c.seenCache.Add(it.id, eventFlushed)
+			c.seenCacheMu.Unlock()
 		case <-c.ctx.Done():
 			return
 		}
 	}
 }
 
+// orderingDelay returns how long incoming events are buffered and sorted by
+// CreatedAt before display. Longer delays order events from slow relays
+// correctly at the cost of showing every message later.
+func (c *client) orderingDelay() time.Duration {
+	if c.config.OrderingDelayMs == 0 {
+		return orderingFlushDelay
+	}
+	d := time.Duration(c.config.OrderingDelayMs) * time.Millisecond
+	return min(max(d, minOrderingDelay), maxOrderingDelay)
+}
+
+// wasFlushed reports whether an event was already sent to the TUI.
+func (c *client) wasFlushed(id string) bool {
+	c.seenCacheMu.Lock()
+	defer c.seenCacheMu.Unlock()
+	state, _ := c.seenCache.Peek(id)
+	return state == eventFlushed
+}
+
 // Message Publishing Lifecycle
 
 func (c *client) publishMessage(message string) {
+	if strings.TrimSpace(sanitizeString(message)) == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Cannot send an empty message."}
+		return
+	}
+
 	var targetChat string
 	var targetPubKey string
 	if strings.HasPrefix(message, "@") {
@@ -551,6 +1218,10 @@ func (c *client) publishMessage(message string) {
 			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Could not find a known user matching your message prefix."}
 			return
 		}
+		if c.config.DMs {
+			c.post(UserAction{Type: "SEND_DM", Payload: targetPubKey + " " + strings.TrimSpace(strings.TrimPrefix(message, matchedReplyTag))})
+			return
+		}
 	} else {
 		activeView := c.getActiveView()
 		if activeView == nil {
@@ -558,16 +1229,155 @@ func (c *client) publishMessage(message string) {
 			return
 		}
 		if activeView.IsGroup {
-			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Broadcasting to a group is disabled. Use @nick to send a message."}
+			if activeView.AllowBroadcast {
+				c.broadcast(message, activeView)
+				return
+			}
+			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Broadcasting to a group is disabled. Use @nick to send a message, or /broadcast on."}
 			return
 		}
 		if activeView.Name == "" {
 			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "The active chat is invalid."}
 			return
 		}
+		if activeView.DM != "" {
+			c.post(UserAction{Type: "SEND_DM", Payload: activeView.DM + " " + message})
+			return
+		}
 		targetChat = activeView.Name
 	}
 
+	var tags nostr.Tags
+	if targetPubKey != "" {
+		tags = append(tags, nostr.Tag{"p", targetPubKey})
+		if !c.warnedPublic.Swap(true) {
+			c.eventsChan <- DisplayEvent{
+				Type:    "STATUS",
+				Content: "Note: @nick messages are public. Anyone in the chat can read them. Use /dm on for encrypted DMs.",
+			}
+		}
+	}
+	c.sendMessage(message, targetChat, tags)
+}
+
+// publishReply sends "<id> <message>" as a NIP-10 reply to a recently shown
+// event, in that event's chat.
+func (c *client) publishReply(payload string) {
+	id, message, _ := strings.Cut(strings.TrimSpace(payload), " ")
+	if strings.TrimSpace(sanitizeString(message)) == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /reply <id> <message>"}
+		return
+	}
+	matches := c.matchRecentEvents(id)
+	switch {
+	case len(matches) == 0:
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("No recent message with id %s.", id)}
+		return
+	case len(matches) > 1:
+		candidates := make([]string, len(matches))
+		for i, ev := range matches {
+			candidates[i] = fmt.Sprintf("%s in %s", safeSuffix(ev.id, 12), ev.chat)
+		}
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Id %s matches %d recent messages: %s. Give more of the id to pick one.",
+			id, len(matches), strings.Join(candidates, ", "))}
+		return
+	}
+	target := matches[0]
+
+	root := target.root
+	if root == "" {
+		root = target.id
+	}
+	tags := nostr.Tags{{"e", root, "", "root"}}
+	if root != target.id {
+		tags = append(tags, nostr.Tag{"e", target.id, "", "reply"})
+	}
+	tags = append(tags, nostr.Tag{"p", target.pubKey})
+	c.sendMessage(message, target.chat, tags)
+}
+
+// broadcast sends message to every chat of group as a separate event, each
+// to the chat's own relay pool. DM views in the group are skipped.
+func (c *client) broadcast(message string, group *View) {
+	var chats []string
+	for _, child := range group.Children {
+		if !strings.HasPrefix(child, dmViewPrefix) {
+			chats = append(chats, child)
+		}
+	}
+	if len(chats) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Group %s has no chats to broadcast to.", group.Name)}
+		return
+	}
+	// A broadcast is one message as far as the rate limit is concerned.
+	if !c.takeSendToken() {
+		return
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Broadcasting to %d chats: %s", len(chats), strings.Join(chats, ", "))}
+
+	results := make(chan deliveryResult, len(chats))
+	report := func(r deliveryResult) { results <- r }
+	for _, chat := range chats {
+		c.sendToChat(message, chat, nil, report)
+	}
+	c.wg.Go(func() { c.reportBroadcast(chats, results) })
+}
+
+// reportBroadcast waits for the delivery result of every chat of a broadcast
+// and emits them as one summary line, in the order the chats were sent to.
+func (c *client) reportBroadcast(chats []string, results <-chan deliveryResult) {
+	byChat := make(map[string]deliveryResult, len(chats))
+	for range chats {
+		select {
+		case r := <-results:
+			byChat[r.chat] = r
+		case <-c.ctx.Done():
+			return
+		}
+	}
+	parts := make([]string, len(chats))
+	for i, chat := range chats {
+		r := byChat[chat]
+		if r.accepted > 0 {
+			parts[i] = fmt.Sprintf("#%s ✓ %d/%d", chat, r.accepted, r.total)
+		} else {
+			parts[i] = fmt.Sprintf("#%s ✗", chat)
+		}
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Broadcast: " + strings.Join(parts, ", ")}
+}
+
+// takeSendToken takes a token from the send rate limiter, emitting an ERROR
+// with the time to wait if there is none.
+func (c *client) takeSendToken() bool {
+	ok, wait := c.sendLimiter.take()
+	if !ok {
+		c.eventsChan <- DisplayEvent{
+			Type:    "ERROR",
+			Content: fmt.Sprintf("Sending too fast. Please wait %ds before sending another message.", int(math.Ceil(wait.Seconds()))),
+		}
+	}
+	return ok
+}
+
+// sendMessage signs and publishes message to targetChat with the given extra tags.
+func (c *client) sendMessage(message, targetChat string, extraTags nostr.Tags) {
+	if !c.takeSendToken() {
+		return
+	}
+	c.sendToChat(message, targetChat, extraTags, nil)
+}
+
+// sendToChat is sendMessage without the rate limit. If report is not nil, it
+// is called exactly once with the delivery result, also when the message is
+// never published.
+func (c *client) sendToChat(message, targetChat string, extraTags nostr.Tags, report func(deliveryResult)) {
+	failed := func() {
+		if report != nil {
+			report(deliveryResult{chat: targetChat})
+		}
+	}
+
 	var kind int
 	var tagKey string
 
@@ -579,62 +1389,220 @@ func (c *client) publishMessage(message string) {
 		tagKey = "d"
 	}
 
-	relayPool := c.getRelayPoolForChat(targetChat)
-	relayPoolSet := make(map[string]struct{}, len(relayPool))
-	for _, url := range relayPool {
-		relayPoolSet[url] = struct{}{}
-	}
-
-	tags := nostr.Tags{{tagKey, targetChat}}
-	if targetPubKey != "" {
-		tags = append(tags, nostr.Tag{"p", targetPubKey})
-	}
+	tags := append(nostr.Tags{{tagKey, targetChat}}, extraTags...)
 
 	activeView := c.getActiveView()
 	if activeView == nil {
 		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Cannot determine PoW: No active chat/group."}
+		failed()
 		return
 	}
 	requiredPoW := c.effectivePoWForChat(targetChat)
 
-	c.relaysMu.Lock()
-	var relaysForPublishing []*managedRelay
-	for url, r := range c.relays {
-		if _, ok := relayPoolSet[url]; !ok {
-			continue
-		}
-		if c.relayFailed(url) {
-			continue
-		}
-		relaysForPublishing = append(relaysForPublishing, r)
+	relaysForPublishing := c.publishRelays(targetChat)
+	if len(relaysForPublishing) == 0 {
+		relaysForPublishing = c.dialGeoRelays(targetChat)
 	}
-	c.relaysMu.Unlock()
-
 	if len(relaysForPublishing) == 0 {
 		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Not connected to any suitable relays for chat %s", targetChat)}
+		failed()
 		return
 	}
 
-	ev := c.createEvent(message, kind, tags, requiredPoW)
+	ev := c.createEvent(message, kind, targetChat, tags, requiredPoW)
+	localID := strconv.FormatUint(c.localMsgSeq.Add(1), 10)
 
 	if requiredPoW > 0 {
-		go c.minePoWAndPublish(ev, requiredPoW, targetChat, relaysForPublishing)
+		go c.minePoWAndPublish(ev, requiredPoW, targetChat, relaysForPublishing, localID, report)
 	} else {
 		if err := c.signEventForChat(&ev, targetChat); err != nil {
 			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Failed to sign event: %v", err)}
+			closeEphemeral(relaysForPublishing)
+			failed()
 			return
 		}
-		c.publish(ev, targetChat, relaysForPublishing)
+		if !c.checkOwnPoW(&ev, requiredPoW) {
+			closeEphemeral(relaysForPublishing)
+			failed()
+			return
+		}
+		c.showOwnMessage(&ev, targetChat, localID)
+		c.publish(ev, targetChat, relaysForPublishing, localID, report)
+	}
+}
+
+// showOwnMessage displays a message being sent right away, marked as pending.
+// The relay echo is suppressed in publish; later updates arrive as MESSAGE_STATUS.
+func (c *client) showOwnMessage(ev *nostr.Event, chat, localID string) {
+	nick, spk := c.eventNick(ev)
+	content := sanitizeString(ev.Content)
+	c.logMessage(ev, chat, nick, spk, content)
+	display, raw := c.displayContent(content, ev.Tags)
+	_, parent := replyTarget(ev.Tags)
+	c.eventsChan <- DisplayEvent{
+		Type:         "NEW_MESSAGE",
+		Timestamp:    time.Unix(int64(ev.CreatedAt), 0).Format(c.timestampFormat()),
+		Nick:         nick,
+		FullPubKey:   ev.PubKey,
+		ShortPubKey:  spk,
+		IsOwnMessage: true,
+		Content:      display,
+		RawContent:   raw,
+		ID:           safeSuffix(ev.ID, 4),
+		FullID:       ev.ID,
+		Chat:         chat,
+		LocalID:      localID,
+		ReplyTo:      parent,
+	}
+}
+
+// rememberEvent records a displayed event so /reply can find it by short ID.
+func (c *client) rememberEvent(ev *nostr.Event, chat, root string) {
+	c.recentEvents.Add(ev.ID, recentEvent{id: ev.ID, root: root, pubKey: ev.PubKey, chat: chat})
+}
+
+// matchRecentEvents resolves the ID given to /reply: the short ID shown with
+// a message, which is the end of its ID, or failing that the start of an ID.
+// Short IDs are cheap to forge, so callers refuse an ID matching several
+// events instead of guessing.
+func (c *client) matchRecentEvents(id string) []recentEvent {
+	id = strings.ToLower(strings.TrimPrefix(id, "#"))
+	if id == "" {
+		return nil
+	}
+	if ev, ok := c.recentEvents.Get(id); ok {
+		return []recentEvent{ev}
+	}
+
+	var bySuffix, byPrefix []recentEvent
+	for _, key := range c.recentEvents.Keys() {
+		ev, ok := c.recentEvents.Peek(key)
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(key, id) {
+			bySuffix = append(bySuffix, ev)
+		} else if strings.HasPrefix(key, id) {
+			byPrefix = append(byPrefix, ev)
+		}
+	}
+	if len(bySuffix) > 0 {
+		return bySuffix
+	}
+	return byPrefix
+}
+
+// replyTarget returns the thread root and the direct parent referenced by
+// the NIP-10 "e" tags. Marked tags are preferred; without markers the first
+// tag is the root and the last one the parent.
+func replyTarget(tags nostr.Tags) (root, parent string) {
+	var first, last string
+	for _, tag := range tags {
+		if len(tag) < 2 || tag[0] != "e" || !nostr.IsValid32ByteHex(tag[1]) {
+			continue
+		}
+		if len(tag) >= 4 {
+			switch tag[3] {
+			case "root":
+				root = tag[1]
+				continue
+			case "reply":
+				parent = tag[1]
+				continue
+			}
+		}
+		if first == "" {
+			first = tag[1]
+		}
+		last = tag[1]
+	}
+	if root == "" && parent == "" {
+		root = first
+		parent = last
+	}
+	if parent == "" {
+		parent = root
+	}
+	return root, parent
+}
+
+// displayContent returns the content to display and, if it differs, the
+// original content for copying.
+func (c *client) displayContent(content string, tags nostr.Tags) (display, raw string) {
+	display = renderEmoji(content, tags)
+	if c.config.RenderNostrRefs {
+		display = renderNostrEntities(display)
+	}
+	if display != content {
+		return display, content
+	}
+	return content, ""
+}
+
+// emojiTags returns NIP-30 emoji tags for the configured shortcodes used in message.
+func (c *client) emojiTags(message string) nostr.Tags {
+	if len(c.config.Emoji) == 0 {
+		return nil
+	}
+	var tags nostr.Tags
+	seen := make(map[string]struct{})
+	for _, m := range shortcodeRe.FindAllStringSubmatch(message, -1) {
+		name := m[1]
+		url, ok := c.config.Emoji[name]
+		if _, dup := seen[name]; !ok || dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		tags = append(tags, nostr.Tag{"emoji", name, url})
+	}
+	return tags
+}
+
+// setMessageStatus reports the delivery state of an own message to the TUI.
+func (c *client) setMessageStatus(localID, eventID, status string) {
+	c.eventsChan <- DisplayEvent{
+		Type:    "MESSAGE_STATUS",
+		ID:      safeSuffix(eventID, 4),
+		FullID:  eventID,
+		LocalID: localID,
+		Content: status,
 	}
 }
 
-func (c *client) createEvent(message string, kind int, tags nostr.Tags, difficulty int) nostr.Event {
+// publishRelays returns the connected relays of a chat's pool that are usable for publishing.
+func (c *client) publishRelays(chat string) []*managedRelay {
+	relayPoolSet := make(map[string]struct{})
+	for _, url := range c.publishPoolForChat(chat) {
+		relayPoolSet[url] = struct{}{}
+	}
+
+	c.relaysMu.Lock()
+	defer c.relaysMu.Unlock()
+	var relays []*managedRelay
+	for url, r := range c.relays {
+		if _, ok := relayPoolSet[url]; !ok {
+			continue
+		}
+		if c.relayFailed(url) {
+			continue
+		}
+		relays = append(relays, r)
+	}
+	return relays
+}
+
+func (c *client) createEvent(message string, kind int, chat string, tags nostr.Tags, difficulty int) nostr.Event {
 	baseTags := make(nostr.Tags, 0, len(tags)+2)
 	baseTags = append(baseTags, tags...)
+	baseTags = append(baseTags, c.emojiTags(message)...)
 
+	// A chat's own nick wins; otherwise a group sends under the main
+	// identity's nick and a chat under its session's.
 	active := c.getActiveView()
-	if active != nil && !active.IsGroup {
-		if session, ok := c.chatKeys[active.Name]; ok && session.nick != "" {
+	if nick := c.config.ChatNicks[chat]; nick != "" && active != nil {
+		baseTags = append(baseTags, nostr.Tag{"n", nick})
+	} else if active != nil && !active.IsGroup {
+		if session, ok := c.chatKeys[chat]; ok && session.nick != "" {
 			baseTags = append(baseTags, nostr.Tag{"n", session.nick})
 		}
 	} else if active != nil && active.IsGroup {
@@ -660,37 +1628,54 @@ func (c *client) createEvent(message string, kind int, tags nostr.Tags, difficul
 	return ev
 }
 
-func (c *client) signEventForChat(ev *nostr.Event, chatName string) error {
-	view := c.getActiveView()
-	useMainKey := false
-
-	if view != nil && view.IsGroup {
-		useMainKey = true
-	}
-
-	if !useMainKey {
+// signingKey returns the key pair messages to chatName are signed with: the
+// chat's session key, or the main key when sending from a group. The public
+// key is derived from the secret key, since that is what Sign puts in the
+// event; a stale pubkey would change the ID after PoW mining.
+func (c *client) signingKey(chatName string) (sk, pk string, err error) {
+	sk = c.sk
+	if view := c.getActiveView(); view == nil || !view.IsGroup {
 		if session, ok := c.chatKeys[chatName]; ok && session.privKey != "" {
-			ev.PubKey = session.pubKey
-			ev.ID = ev.GetID()
-			return ev.Sign(session.privKey)
+			sk = session.privKey
 		}
 	}
-
-	if c.sk == "" || c.pk == "" {
-		return fmt.Errorf("no valid signing key available")
+	if sk == "" {
+		return "", "", fmt.Errorf("no valid signing key available")
 	}
+	pk, err = nostr.GetPublicKey(sk)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid signing key: %w", err)
+	}
+	return sk, pk, nil
+}
 
-	ev.PubKey = c.pk
+func (c *client) signEventForChat(ev *nostr.Event, chatName string) error {
+	sk, pk, err := c.signingKey(chatName)
+	if err != nil {
+		return err
+	}
+	ev.PubKey = pk
 	ev.ID = ev.GetID()
-	return ev.Sign(c.sk)
+	return ev.Sign(sk)
 }
 
-func (c *client) minePoWAndPublish(ev nostr.Event, difficulty int, targetChat string, relays []*managedRelay) {
-	if session, ok := c.chatKeys[targetChat]; ok && session.privKey != "" {
-		ev.PubKey = session.pubKey
-	} else {
-		ev.PubKey = c.pk
+func (c *client) minePoWAndPublish(ev nostr.Event, difficulty int, targetChat string, relays []*managedRelay, localID string, report func(deliveryResult)) {
+	published := false
+	defer func() {
+		if !published && report != nil {
+			report(deliveryResult{chat: targetChat})
+		}
+	}()
+
+	// The pubkey is part of the hashed serialization, so it must be final
+	// before mining.
+	sk, pk, err := c.signingKey(targetChat)
+	if err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Failed to sign event: %v", err)}
+		closeEphemeral(relays)
+		return
 	}
+	ev.PubKey = pk
 
 	c.eventsChan <- DisplayEvent{Type: "STATUS",
 		Content: fmt.Sprintf("Calculating Proof-of-Work (difficulty %d)...", difficulty),
@@ -705,37 +1690,257 @@ func (c *client) minePoWAndPublish(ev nostr.Event, difficulty int, targetChat st
 	}
 	if nonceTagIndex == -1 {
 		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "PoW mining failed: nonce tag not found."}
+		closeEphemeral(relays)
 		return
 	}
 
-	var nonceCounter uint64
+	c.showOwnMessage(&ev, targetChat, localID)
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.miningMu.Lock()
+	c.mining[localID] = cancel
+	c.miningMu.Unlock()
+	defer func() {
+		c.miningMu.Lock()
+		delete(c.mining, localID)
+		c.miningMu.Unlock()
+		cancel()
+	}()
+
+	start := time.Now()
+	c.eventsChan <- DisplayEvent{Type: "POW_PROGRESS", Content: fmt.Sprintf("best 0/%d bits, 0s", difficulty)}
+	defer func() { c.eventsChan <- DisplayEvent{Type: "POW_PROGRESS"} }()
+
+	mined, ok := minePoW(ctx, ev, nonceTagIndex, difficulty, c.powNonceStart(difficulty), func(best int) {
+		c.eventsChan <- DisplayEvent{
+			Type:    "POW_PROGRESS",
+			Content: fmt.Sprintf("best %d/%d bits, %s", best, difficulty, time.Since(start).Round(time.Second)),
+		}
+	})
+	if !ok {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "PoW calculation cancelled."}
+		c.setMessageStatus(localID, "", MsgStatusFailed)
+		closeEphemeral(relays)
+		return
+	}
+	ev = mined
+	c.rememberPoWNonce(difficulty, &ev)
+
+	minedID := ev.ID
+	if err := ev.Sign(sk); err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Failed to sign event: %v", err)}
+		c.setMessageStatus(localID, "", MsgStatusFailed)
+		closeEphemeral(relays)
+		return
+	}
+	if ev.ID != minedID {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Signing changed the mined event ID (%s -> %s). Not publishing.", safeSuffix(minedID, 4), safeSuffix(ev.ID, 4))}
+		c.setMessageStatus(localID, "", MsgStatusFailed)
+		closeEphemeral(relays)
+		return
+	}
+	if !c.checkOwnPoW(&ev, difficulty) {
+		c.setMessageStatus(localID, ev.ID, MsgStatusFailed)
+		closeEphemeral(relays)
+		return
+	}
+
+	published = true
+	c.publish(ev, targetChat, relays, localID, report)
+}
+
+// checkOwnPoW reports whether a signed outgoing event meets difficulty,
+// emitting an ERROR if it does not, so relays never see a broken nonce.
+func (c *client) checkOwnPoW(ev *nostr.Event, difficulty int) bool {
+	if isPoWValid(ev, difficulty) {
+		return true
+	}
+	c.eventsChan <- DisplayEvent{
+		Type:    "ERROR",
+		Content: fmt.Sprintf("Event %s fails its own PoW check (required: %d). Not publishing.", safeSuffix(ev.ID, 4), difficulty),
+	}
+	return false
+}
+
+func (c *client) publish(ev nostr.Event, targetChat string, relaysForPublishing []*managedRelay, localID string, report func(deliveryResult)) {
+	root, _ := replyTarget(ev.Tags)
+	c.rememberEvent(&ev, targetChat, root)
+
+	// The message is already displayed; don't show the relay echo again.
+	c.seenCacheMu.Lock()
+	c.seenCache.Add(ev.ID, eventFlushed)
+	c.seenCacheMu.Unlock()
+
+	// Every message goes through the chat's outbox so that a message waiting
+	// for a retry is never overtaken by a later one.
+	c.outboxMu.Lock()
+	_, running := c.outbox[targetChat]
+	c.outbox[targetChat] = append(c.outbox[targetChat], outboxItem{ev: ev, localID: localID, relays: relaysForPublishing, report: report})
+	c.outboxMu.Unlock()
+
+	if !running {
+		go c.runOutbox(targetChat)
+	}
+}
+
+// runOutbox delivers a chat's queued messages in order until the queue is empty.
+func (c *client) runOutbox(chat string) {
 	for {
-		ev.Tags[nonceTagIndex][1] = strconv.FormatUint(nonceCounter, 10)
-		ev.ID = ev.GetID()
-		if countLeadingZeroBits(ev.ID) >= difficulty {
-			break
+		c.outboxMu.Lock()
+		queue := c.outbox[chat]
+		if len(queue) == 0 {
+			delete(c.outbox, chat)
+			c.outboxMu.Unlock()
+			return
 		}
-		nonceCounter++
-		if nonceCounter&0x3FF == 0 {
-			select {
-			case <-c.ctx.Done():
-				c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "PoW calculation cancelled."}
-				return
-			default:
+		item := queue[0]
+		c.outboxMu.Unlock()
+
+		c.deliver(item, chat)
+
+		c.outboxMu.Lock()
+		c.outbox[chat] = c.outbox[chat][1:]
+		c.outboxMu.Unlock()
+
+		if c.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// deliver publishes an event, retrying with backoff while no relay accepts it.
+func (c *client) deliver(item outboxItem, chat string) {
+	defer closeEphemeral(item.relays)
+	result := deliveryResult{chat: chat, total: len(item.relays)}
+	if item.report != nil {
+		defer func() { item.report(result) }()
+	}
+	if n := c.sendToRelays(item.ev, chat, item.relays); n > 0 {
+		result.accepted = n
+		c.setMessageStatus(item.localID, item.ev.ID, MsgStatusSent)
+		return
+	}
+
+	retries := c.publishRetries()
+	if retries > 0 {
+		c.eventsChan <- DisplayEvent{
+			Type:    "STATUS",
+			Content: fmt.Sprintf("Event %s reached no relay for %s. Retrying up to %d times...", safeSuffix(item.ev.ID, 4), chat, retries),
+		}
+	}
+
+	for attempt := 1; attempt <= retries; attempt++ {
+		err := retryWithBackoff(c.ctx, func() error {
+			relays := c.publishRelays(chat)
+			if len(relays) == 0 {
+				return fmt.Errorf("no suitable relays for %s", chat)
+			}
+			result.total = len(relays)
+			result.accepted = c.sendToRelays(item.ev, chat, relays)
+			if result.accepted == 0 {
+				return fmt.Errorf("no relay accepted the event")
+			}
+			return nil
+		}, attempt)
+		if err == nil {
+			c.eventsChan <- DisplayEvent{
+				Type:    "STATUS",
+				Content: fmt.Sprintf("Event %s delivered to %s after %d retries.", safeSuffix(item.ev.ID, 4), chat, attempt),
 			}
+			c.setMessageStatus(item.localID, item.ev.ID, MsgStatusSent)
+			return
+		}
+		if c.ctx.Err() != nil {
+			return
 		}
 	}
 
-	if session, ok := c.chatKeys[targetChat]; ok && session.privKey != "" {
-		_ = ev.Sign(session.privKey)
-	} else {
-		_ = ev.Sign(c.sk)
+	if retries > 0 {
+		c.eventsChan <- DisplayEvent{
+			Type:    "ERROR",
+			Content: fmt.Sprintf("Event %s for %s dropped after %d retries.", safeSuffix(item.ev.ID, 4), chat, retries),
+		}
+	}
+	c.setMessageStatus(item.localID, item.ev.ID, MsgStatusFailed)
+}
+
+// cancelPoW aborts every PoW calculation in progress. The messages being
+// mined are marked failed.
+func (c *client) cancelPoW() {
+	c.miningMu.Lock()
+	n := len(c.mining)
+	for _, cancel := range c.mining {
+		cancel()
+	}
+	c.miningMu.Unlock()
+	if n == 0 {
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: "No PoW calculation in progress."}
+	}
+}
+
+// dialGeoRelays connects to the closest geo relays of a geohash chat for a
+// single publish, for when none of them is connected yet (e.g. right after
+// switching chats).
+func (c *client) dialGeoRelays(chat string) []*managedRelay {
+	if geohash.Validate(chat) != nil || c.config.DisableGeoRelays {
+		return nil
+	}
+	closest, err := closestRelays(chat, c.geoRelayCount(), c.config.GeoRelaysPath, c.config.GeoRelaysURL)
+	if err != nil || len(closest) == 0 {
+		return nil
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("No relay connected for %s yet. Dialing %d geo relays to publish...", chat, len(closest))}
+	return c.dialEphemeral(closest)
+}
+
+// dialEphemeral connects to urls for a single publish, skipping relays in
+// the fail cache. The connections are not added to c.relays.
+func (c *client) dialEphemeral(urls []string) []*managedRelay {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		relays []*managedRelay
+	)
+	for _, url := range urls {
+		if c.relayFailed(url) {
+			continue
+		}
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(c.ctx, c.connectTimeout())
+			defer cancel()
+			start := time.Now()
+			relay, err := nostr.RelayConnect(ctx, url)
+			if err != nil {
+				log.Printf("Could not dial relay %s for publishing: %v", url, err)
+				return
+			}
+			mu.Lock()
+			relays = append(relays, &managedRelay{
+				url:       url,
+				relay:     relay,
+				latency:   time.Since(start),
+				connected: true,
+				ephemeral: true,
+			})
+			mu.Unlock()
+		})
 	}
+	wg.Wait()
+	return relays
+}
 
-	c.publish(ev, targetChat, relays)
+// closeEphemeral closes the connections dialEphemeral opened.
+func closeEphemeral(relays []*managedRelay) {
+	for _, r := range relays {
+		if r.ephemeral {
+			r.relay.Close()
+		}
+	}
 }
 
-func (c *client) publish(ev nostr.Event, targetChat string, relaysForPublishing []*managedRelay) {
+// sendToRelays publishes an event to the given relays in parallel and
+// returns how many of them accepted it.
+func (c *client) sendToRelays(ev nostr.Event, targetChat string, relaysForPublishing []*managedRelay) int {
 	sort.Slice(relaysForPublishing, func(i, j int) bool {
 		return relaysForPublishing[i].latency < relaysForPublishing[j].latency
 	})
@@ -769,6 +1974,9 @@ func (c *client) publish(ev nostr.Event, targetChat string, relaysForPublishing
 		}(r)
 	}
 	wg.Wait()
+	if successCount > 0 {
+		c.stats.sent.Add(1)
+	}
 
 	c.eventsChan <- DisplayEvent{
 		Type: "STATUS",
@@ -787,11 +1995,178 @@ func (c *client) publish(ev nostr.Event, targetChat string, relaysForPublishing
 			}
 		}
 	}
+
+	return successCount
 }
 
 // Helpers
 
+// eventNick returns the display nick and short pubkey of an event's author.
+func (c *client) eventNick(ev *nostr.Event) (nick, spk string) {
+	n := c.shortPubKeyLen()
+	nick = npubToTokiPona(ev.PubKey)
+	spk = ev.PubKey[:n]
+	if nickTag := ev.Tags.Find("n"); len(nickTag) > 1 {
+		if s := sanitizeString(nickTag[1]); s != "" {
+			nick = s
+		}
+		spk = safeSuffix(ev.PubKey, n)
+	}
+	return nick, spk
+}
+
+// disambiguate lengthens spk, the short pubkey shown after nick, until no
+// other known user with the same nick has an id that is a prefix of it or
+// the other way round, so @nick#id always targets one pubkey. Colliding
+// users are lengthened to match.
+func (c *client) disambiguate(pk, nick, spk string) string {
+	type other struct {
+		pk  string
+		ctx userContext
+	}
+	var same []other
+	for _, opk := range c.userContext.Keys() {
+		if opk == pk {
+			continue
+		}
+		if octx, ok := c.userContext.Peek(opk); ok && octx.nick == nick {
+			same = append(same, other{opk, octx})
+		}
+	}
+	if len(same) == 0 {
+		return spk
+	}
+
+	n := len(spk)
+	for ; n < len(pk); n++ {
+		clash := false
+		for _, o := range same {
+			if extendShortPubKey(o.pk, o.ctx.shortPubKey, n) == extendShortPubKey(pk, spk, n) {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			break
+		}
+	}
+
+	candidate := extendShortPubKey(pk, spk, n)
+	for _, o := range same {
+		if strings.HasPrefix(o.ctx.shortPubKey, candidate) || strings.HasPrefix(candidate, o.ctx.shortPubKey) {
+			o.ctx.shortPubKey = extendShortPubKey(o.pk, o.ctx.shortPubKey, n)
+			c.userContext.Add(o.pk, o.ctx)
+		}
+	}
+	return candidate
+}
+
+// extendShortPubKey returns the n-char id of pk taken from the same end as spk.
+func extendShortPubKey(pk, spk string, n int) string {
+	n = min(n, len(pk))
+	if strings.HasPrefix(pk, spk) {
+		return pk[:n]
+	}
+	return safeSuffix(pk, n)
+}
+
+// shortPubKeyLen returns how many hex chars of a pubkey follow the nick.
+func (c *client) shortPubKeyLen() int {
+	if c.config.ShortPubKeyLen == 0 {
+		return defaultShortPubKeyLen
+	}
+	return min(max(c.config.ShortPubKeyLen, minShortPubKeyLen), maxShortPubKeyLen)
+}
+
+// timestampFormat returns the configured Go time layout for message timestamps.
+func (c *client) timestampFormat() string {
+	if c.config.TimestampFormat != "" {
+		return c.config.TimestampFormat
+	}
+	return defaultTimestampFormat
+}
+
+// publishRetries returns how often a message that reached no relay is retried.
+// A negative setting disables retries.
+func (c *client) publishRetries() int {
+	switch {
+	case c.config.PublishRetries < 0:
+		return 0
+	case c.config.PublishRetries > 0:
+		return c.config.PublishRetries
+	}
+	return defaultPublishRetries
+}
+
+// connectTimeout bounds dialing a relay, for subscriptions, discovery and
+// one-off publishes alike.
+func (c *client) connectTimeout() time.Duration {
+	if c.config.ConnectTimeoutSec > 0 {
+		return time.Duration(c.config.ConnectTimeoutSec) * time.Second
+	}
+	return defaultConnTimeout * time.Second
+}
+
+// pingInterval returns the relay health check interval. A negative setting disables it.
+func (c *client) pingInterval() time.Duration {
+	switch {
+	case c.config.RelayPingInterval < 0:
+		return 0
+	case c.config.RelayPingInterval > 0:
+		return time.Duration(c.config.RelayPingInterval) * time.Second
+	}
+	return defaultPingInterval * time.Second
+}
+
+// geoRelayCount returns how many of the closest geo relays a geochat uses.
+func (c *client) geoRelayCount() int {
+	if n := c.config.GeoRelayCount; n >= 1 && n <= maxGeoRelayCount {
+		return n
+	}
+	return defaultRelayCount
+}
+
+// maxMsgLen returns the configured message length limit, clamped to a sane range.
+func (c *client) maxMsgLen() int {
+	if c.config.MaxMsgLen == 0 {
+		return DefaultMaxMsgLen
+	}
+	return min(max(c.config.MaxMsgLen, minMsgLen), maxMsgLenLimit)
+}
+
+// maxClockSkew returns the threshold in seconds above which a message is marked as skewed.
+func (c *client) maxClockSkew() int64 {
+	if c.config.MaxClockSkew > 0 {
+		return int64(c.config.MaxClockSkew)
+	}
+	return defaultMaxClockSkew
+}
+
 func (c *client) effectivePoWForChat(chat string) int {
+	pow := c.userPoWForChat(chat)
+	if c.config.AutoPoW {
+		c.autoPoWMu.Lock()
+		pow = max(pow, c.autoPoW[chat])
+		c.autoPoWMu.Unlock()
+	}
+	return pow
+}
+
+// effectivePoWForView returns the difficulty messages to a view are mined
+// with; for a group, the highest among its chats.
+func (c *client) effectivePoWForView(v *View) int {
+	if !v.IsGroup {
+		return c.effectivePoWForChat(v.Name)
+	}
+	pow := v.PoW
+	for _, chat := range v.Children {
+		pow = max(pow, c.effectivePoWForChat(chat))
+	}
+	return pow
+}
+
+// userPoWForChat returns the difficulty set with /pow for a chat or its active group.
+func (c *client) userPoWForChat(chat string) int {
 	for _, v := range c.config.Views {
 		if !v.IsGroup && v.Name == chat && v.PoW > 0 {
 			return v.PoW
@@ -819,6 +2194,20 @@ func retryWithBackoff(ctx context.Context, fn func() error, attempt int) error {
 	}
 }
 
+// chatFilter returns the base subscription filter for a geohash or named chat.
+func chatFilter(chat string) nostr.Filter {
+	if geohash.Validate(chat) == nil {
+		return nostr.Filter{
+			Kinds: []int{geoChatKind},
+			Tags:  nostr.TagMap{"g": []string{chat}},
+		}
+	}
+	return nostr.Filter{
+		Kinds: []int{ephChatKind},
+		Tags:  nostr.TagMap{"d": []string{chat}},
+	}
+}
+
 func mrCurrentChatsLocked(sub *nostr.Subscription) []string {
 	if sub == nil {
 		return nil
@@ -826,6 +2215,13 @@ func mrCurrentChatsLocked(sub *nostr.Subscription) []string {
 	seen := make(map[string]struct{})
 	var out []string
 	for _, f := range sub.Filters {
+		if slices.Contains(f.Kinds, nostr.KindGiftWrap) {
+			if _, exists := seen[dmInboxChat]; !exists {
+				seen[dmInboxChat] = struct{}{}
+				out = append(out, dmInboxChat)
+			}
+			continue
+		}
 		tagsToCheck := [][]string{}
 		if gTags, ok := f.Tags["g"]; ok {
 			tagsToCheck = append(tagsToCheck, gTags)
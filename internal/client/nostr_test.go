@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// newOrderingTestClient returns a client with what the ordering buffer
+// needs. Flushes happen in the test; the timers enqueueOrdered starts only
+// find empty buffers.
+func newOrderingTestClient(t *testing.T) (*client, <-chan DisplayEvent) {
+	t.Helper()
+	seenCache, err := lru.New[string, eventState](seenCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	events := make(chan DisplayEvent, perStreamBufferMax)
+	return &client{
+		config:      &config{},
+		eventsChan:  events,
+		seenCache:   seenCache,
+		orderBuf:    make(map[string][]orderItem),
+		orderTimers: make(map[string]*time.Timer),
+		ctx:         ctx,
+		cancel:      cancel,
+	}, events
+}
+
+// receive marks id as seen and buffers it, as processEvent does.
+func receive(c *client, streamKey, id string, createdAt int64) {
+	c.seenCacheMu.Lock()
+	if c.seenCache.Contains(id) {
+		c.seenCacheMu.Unlock()
+		return
+	}
+	c.seenCache.Add(id, eventSeen)
+	c.seenCacheMu.Unlock()
+	c.enqueueOrdered(streamKey, DisplayEvent{Type: "NEW_MESSAGE", ID: id}, createdAt, id)
+}
+
+// drain returns the IDs of the events sent to the TUI so far.
+func drain(events <-chan DisplayEvent) []string {
+	var ids []string
+	for {
+		select {
+		case ev := <-events:
+			ids = append(ids, ev.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestFlushedEventNotShownAgain(t *testing.T) {
+	c, events := newOrderingTestClient(t)
+	receive(c, "chat:a", "e1", 100)
+	c.flushOrdered("chat:a")
+
+	// A resubscribe backfills from lastAlive, redelivering e1. The second
+	// copy goes through enqueueOrdered directly too, as a stream switch
+	// would hand it over.
+	receive(c, "chat:a", "e1", 100)
+	c.enqueueOrdered("group:g", DisplayEvent{Type: "NEW_MESSAGE", ID: "e1"}, 100, "e1")
+	receive(c, "chat:a", "e2", 101)
+	c.flushOrdered("chat:a")
+	c.flushOrdered("group:g")
+
+	got := drain(events)
+	if want := []string{"e1", "e2"}; !slices.Equal(got, want) {
+		t.Errorf("shown %v, want %v", got, want)
+	}
+}
+
+func TestQueuedEventInOneBufferOnly(t *testing.T) {
+	c, events := newOrderingTestClient(t)
+	receive(c, "chat:a", "e1", 100)
+	c.enqueueOrdered("group:g", DisplayEvent{Type: "NEW_MESSAGE", ID: "e1"}, 100, "e1")
+	c.flushOrdered("group:g")
+	c.flushOrdered("chat:a")
+
+	if got := drain(events); !slices.Equal(got, []string{"e1"}) {
+		t.Errorf("shown %v, want e1 once", got)
+	}
+}
+
+// TestMinedEventKeepsIDAfterSigning follows minePoWAndPublish: the pubkey is
+// set from signingKey before mining, so signing must not change the mined ID.
+func TestMinedEventKeepsIDAfterSigning(t *testing.T) {
+	const chat, difficulty = "test", 12
+	mainSK := nostr.GeneratePrivateKey()
+	chatSK := nostr.GeneratePrivateKey()
+	chatPK, _ := nostr.GetPublicKey(chatSK)
+	c := &client{
+		sk:         mainSK,
+		config:     &config{Views: []View{{Name: chat}}, ActiveViewName: chat},
+		chatKeys:   map[string]chatSession{chat: {privKey: chatSK, pubKey: chatPK}},
+		eventsChan: make(chan DisplayEvent, 16),
+	}
+
+	sk, pk, err := c.signingKey(chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk != chatPK {
+		t.Fatalf("signingKey pubkey = %s, want the chat session's %s", pk, chatPK)
+	}
+	ev := c.createEvent("hello", ephChatKind, chat, nostr.Tags{{"d", chat}}, difficulty)
+	ev.PubKey = pk
+
+	nonceIdx := len(ev.Tags) - 1
+	mined, ok := minePoW(context.Background(), ev, nonceIdx, difficulty, 0, func(int) {})
+	if !ok {
+		t.Fatal("minePoW gave up")
+	}
+	minedID := mined.ID
+	if err := mined.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+
+	if mined.ID != minedID {
+		t.Errorf("signing changed the ID from %s to %s", minedID, mined.ID)
+	}
+	if bits := countLeadingZeroBits(mined.ID); bits < difficulty {
+		t.Errorf("signed ID %s has %d leading zero bits, want at least %d", mined.ID, bits, difficulty)
+	}
+	if !c.checkOwnPoW(&mined, difficulty) {
+		t.Errorf("checkOwnPoW rejected the signed event")
+	}
+	if ok, err := mined.CheckSignature(); !ok || err != nil {
+		t.Errorf("signature check failed: %v", err)
+	}
+}
+
+func TestFlushOrderedSortsByCreatedAtThenID(t *testing.T) {
+	c, events := newOrderingTestClient(t)
+	for _, it := range []struct {
+		id        string
+		createdAt int64
+	}{
+		{"e3", 300},
+		{"b2", 200},
+		{"e1", 100},
+		{"a2", 200}, // same timestamp as b2: the lower ID goes first
+		{"c2", 200},
+	} {
+		receive(c, "chat:a", it.id, it.createdAt)
+	}
+	c.flushOrdered("chat:a")
+
+	if got, want := drain(events), []string{"e1", "a2", "b2", "c2", "e3"}; !slices.Equal(got, want) {
+		t.Errorf("flushed %v, want %v", got, want)
+	}
+}
+
+func TestMatchRecentEvents(t *testing.T) {
+	recentEvents, err := lru.New[string, recentEvent](recentEventsSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{recentEvents: recentEvents}
+	victim := "aaaa" + strings.Repeat("0", 56) + "beef"
+	forged := "bbbb" + strings.Repeat("1", 56) + "beef" // mined to end like victim
+	other := "cccc" + strings.Repeat("2", 56) + "f00d"
+	for _, id := range []string{victim, forged, other} {
+		c.rememberEvent(&nostr.Event{ID: id}, "chat", "")
+	}
+
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{"f00d", []string{other}},
+		{"#F00D", []string{other}},
+		{"beef", []string{victim, forged}},
+		{"0beef", []string{victim}},
+		{"cccc", []string{other}},
+		{victim, []string{victim}},
+		{"dead", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, ev := range c.matchRecentEvents(tt.id) {
+			got = append(got, ev.id)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("matchRecentEvents(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestProcessEventSkewOnlyForLiveEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     bool
+		wantSkew bool
+	}{
+		{"stored event from an hour ago", false, false},
+		{"live event from an hour ago", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const chat = "test"
+			c, events := newOrderingTestClient(t)
+			userContexts, _ := lru.New[string, userContext](userContextCacheSize)
+			recentEvents, _ := lru.New[string, recentEvent](recentEventsSize)
+			floodCache, _ := lru.New[string, floodEntry](floodCacheSize)
+			c.userContext = userContexts
+			c.recentEvents = recentEvents
+			c.floodCache = floodCache
+
+			ev := nostr.Event{
+				Kind:      ephChatKind,
+				CreatedAt: nostr.Now() - 3600,
+				Tags:      nostr.Tags{{"d", chat}, {"n", "alice"}},
+				Content:   "hello",
+			}
+			if err := ev.Sign(nostr.GeneratePrivateKey()); err != nil {
+				t.Fatal(err)
+			}
+			c.processEvent(&ev, "wss://relay.example", tt.live)
+			c.flushOrdered("chat:" + chat)
+
+			select {
+			case de := <-events:
+				if (de.Skew != 0) != tt.wantSkew {
+					t.Errorf("Skew = %d, want skewed %v", de.Skew, tt.wantSkew)
+				}
+			default:
+				t.Fatal("event was not shown")
+			}
+		})
+	}
+}
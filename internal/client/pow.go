@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// powNonceStart returns the nonce to start mining at for difficulty: just
+// past the last nonce found at that difficulty, or a random one, so that
+// clients mining identical events don't all walk the same nonces.
+func (c *client) powNonceStart(difficulty int) uint64 {
+	c.miningMu.Lock()
+	defer c.miningMu.Unlock()
+	if start, ok := c.powOffsets[difficulty]; ok {
+		return start
+	}
+	return rand.Uint64() & powNonceMask
+}
+
+// rememberPoWNonce records where the next mine at difficulty should start.
+func (c *client) rememberPoWNonce(difficulty int, ev *nostr.Event) {
+	tag := ev.Tags.FindLast("nonce")
+	if len(tag) < 2 {
+		return
+	}
+	nonce, err := strconv.ParseUint(tag[1], 10, 64)
+	if err != nil {
+		return
+	}
+	c.miningMu.Lock()
+	c.powOffsets[difficulty] = (nonce + 1) & powNonceMask
+	c.miningMu.Unlock()
+}
+
+// minePoW searches for a nonce that gives ev an ID with at least difficulty
+// leading zero bits. It runs one worker per CPU, worker w trying the nonces
+// start+w, start+w+n, ... so no two workers hash the same event. progress is
+// called periodically with the best bit count so far. It reports false if
+// ctx ends first.
+func minePoW(ctx context.Context, ev nostr.Event, nonceIdx, difficulty int, start uint64, progress func(best int)) (nostr.Event, bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := runtime.NumCPU()
+	found := make(chan nostr.Event, 1)
+	var best atomic.Int32
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Go(func() {
+			e := ev
+			e.Tags = slices.Clone(ev.Tags)
+			e.Tags[nonceIdx] = slices.Clone(ev.Tags[nonceIdx])
+
+			for i, nonce := uint64(0), start+uint64(w); ; i, nonce = i+1, nonce+uint64(workers) {
+				e.Tags[nonceIdx][1] = strconv.FormatUint(nonce, 10)
+				id := e.GetID()
+				bits := countLeadingZeroBits(id)
+				if bits >= difficulty {
+					e.ID = id
+					select {
+					case found <- e:
+					default:
+					}
+					cancel()
+					return
+				}
+				for {
+					b := best.Load()
+					if int32(bits) <= b || best.CompareAndSwap(b, int32(bits)) {
+						break
+					}
+				}
+				if i&0x3FF == 0 && ctx.Err() != nil {
+					return
+				}
+			}
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(powProgressPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			select {
+			case e := <-found:
+				return e, true
+			default:
+				return ev, false
+			}
+		case <-ticker.C:
+			progress(int(best.Load()))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// powTestEvent returns an unsigned chat event by pk with a nonce tag for
+// difficulty, and the index of that tag.
+func powTestEvent(pk string, difficulty int) (nostr.Event, int) {
+	ev := nostr.Event{
+		PubKey:    pk,
+		CreatedAt: nostr.Now(),
+		Kind:      ephChatKind,
+		Content:   "hello",
+		Tags:      nostr.Tags{{"d", "test"}, {"nonce", "0", fmt.Sprint(difficulty)}},
+	}
+	return ev, 1
+}
+
+func TestMinePoW(t *testing.T) {
+	for _, difficulty := range []int{1, 4, 8, 12} {
+		t.Run(fmt.Sprintf("difficulty %d", difficulty), func(t *testing.T) {
+			sk := nostr.GeneratePrivateKey()
+			pk, _ := nostr.GetPublicKey(sk)
+			ev, nonceIdx := powTestEvent(pk, difficulty)
+
+			mined, ok := minePoW(context.Background(), ev, nonceIdx, difficulty, 0, func(int) {})
+			if !ok {
+				t.Fatal("minePoW gave up")
+			}
+			if mined.ID != mined.GetID() {
+				t.Errorf("ID %s does not match the mined event", mined.ID)
+			}
+			if err := mined.Sign(sk); err != nil {
+				t.Fatal(err)
+			}
+			if !isPoWValid(&mined, difficulty) {
+				t.Errorf("event %s fails isPoWValid(%d)", mined.ID, difficulty)
+			}
+			if ok, err := mined.CheckSignature(); !ok || err != nil {
+				t.Errorf("signature check failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestMinePoWCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pk, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	ev, nonceIdx := powTestEvent(pk, 256)
+	if _, ok := minePoW(ctx, ev, nonceIdx, 256, 0, func(int) {}); ok {
+		t.Error("minePoW succeeded after its context was cancelled")
+	}
+}
+
+func BenchmarkMinePoW(b *testing.B) {
+	pk, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	for _, difficulty := range []int{8, 12, 16} {
+		b.Run(fmt.Sprintf("difficulty %d", difficulty), func(b *testing.B) {
+			ev, nonceIdx := powTestEvent(pk, difficulty)
+			var start uint64
+			for b.Loop() {
+				mined, ok := minePoW(context.Background(), ev, nonceIdx, difficulty, start, func(int) {})
+				if !ok {
+					b.Fatal("minePoW gave up")
+				}
+				// Continue past the last find, as rememberPoWNonce does,
+				// so every iteration searches fresh nonces.
+				nonce, _ := strconv.ParseUint(mined.Tags[nonceIdx][1], 10, 64)
+				start = nonce + 1
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+// NIP-11 relay information documents
+
+// fetchRelayInfo downloads and caches the NIP-11 document of a relay.
+// Cached documents are returned without a new request.
+func (c *client) fetchRelayInfo(url string) (nip11.RelayInformationDocument, error) {
+	c.relayInfoMu.Lock()
+	info, ok := c.relayInfo[url]
+	c.relayInfoMu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.connectTimeout())
+	defer cancel()
+	info, err := nip11.Fetch(ctx, url)
+	if err != nil {
+		return info, err
+	}
+
+	c.relayInfoMu.Lock()
+	c.relayInfo[url] = info
+	c.relayInfoMu.Unlock()
+
+	if info.Limitation != nil && info.Limitation.MinPowDifficulty > 0 && !c.config.AutoPoW {
+		pow := info.Limitation.MinPowDifficulty
+		c.eventsChan <- DisplayEvent{
+			Type:    "INFO",
+			Content: fmt.Sprintf("Hint: relay %s requires PoW %d. Try `/pow %d` for chats using it.", url, pow, pow),
+		}
+	}
+	return info, nil
+}
+
+// prefetchRelayInfo fetches the NIP-11 document of a newly connected relay.
+// Run it in c.wg; it is cut short when the client shuts down.
+func (c *client) prefetchRelayInfo(url string) {
+	if _, err := c.fetchRelayInfo(url); err != nil && c.ctx.Err() == nil {
+		log.Printf("Could not fetch relay info for %s: %v", url, err)
+	}
+}
+
+// applyAutoPoW raises the effective PoW of each chat to the highest
+// min_pow_difficulty advertised by the relays in its pool.
+func (c *client) applyAutoPoW(chats map[string]struct{}) {
+	for chat := range chats {
+		var (
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			minPoW int
+		)
+		for _, url := range c.getRelayPoolForChat(chat) {
+			wg.Go(func() {
+				info, err := c.fetchRelayInfo(url)
+				if err != nil || info.Limitation == nil {
+					return
+				}
+				mu.Lock()
+				minPoW = max(minPoW, info.Limitation.MinPowDifficulty)
+				mu.Unlock()
+			})
+		}
+		wg.Wait()
+
+		c.autoPoWMu.Lock()
+		prev := c.autoPoW[chat]
+		c.autoPoW[chat] = minPoW
+		c.autoPoWMu.Unlock()
+
+		if minPoW != prev && minPoW > c.userPoWForChat(chat) {
+			c.eventsChan <- DisplayEvent{
+				Type:    "STATUS",
+				Content: fmt.Sprintf("Auto PoW: relays for %s require difficulty %d, using it.", chat, minPoW),
+			}
+		}
+		if minPoW != prev {
+			c.sendStateUpdate()
+		}
+	}
+}
+
+// connectedRelayURLs returns the URLs of all connected relays, sorted as in the Info pane.
+func (c *client) connectedRelayURLs() []string {
+	c.relaysMu.Lock()
+	urls := make([]string, 0, len(c.relays))
+	for url := range c.relays {
+		urls = append(urls, url)
+	}
+	c.relaysMu.Unlock()
+	slices.Sort(urls)
+	return urls
+}
+
+// showRelayInfo lists the connected relays or shows the NIP-11 document of one of them.
+func (c *client) showRelayInfo(payload string) {
+	urls := c.connectedRelayURLs()
+	arg := strings.TrimSpace(payload)
+
+	if arg == "" {
+		if len(urls) == 0 {
+			c.eventsChan <- DisplayEvent{Type: "INFO", Content: "Not connected to any relays."}
+			return
+		}
+		var builder strings.Builder
+		builder.WriteString("Connected Relays:\n")
+		for i, url := range urls {
+			builder.WriteString(fmt.Sprintf("[%d] %s\n", i+1, url))
+		}
+		builder.WriteString("Use /relay info <num> for details.")
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: builder.String()}
+		return
+	}
+
+	url, ok := c.resolveConnectedRelay(arg)
+	if !ok {
+		return
+	}
+
+	info, err := c.fetchRelayInfo(url)
+	if err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not fetch relay info for %s: %v", url, err)}
+		return
+	}
+	c.eventsChan <- DisplayEvent{Type: "INFO", Content: formatRelayInfo(url, info)}
+}
+
+// resolveConnectedRelay maps a /relay argument (index into the connected relays
+// list or a URL) to a relay URL, reporting an error for a bad index.
+func (c *client) resolveConnectedRelay(arg string) (string, bool) {
+	if arg == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Specify a relay number or URL. Use /relay info to see the list."}
+		return "", false
+	}
+	idx, err := strconv.Atoi(arg)
+	if err != nil {
+		if norm, err := normalizeRelayURL(arg); err == nil {
+			return norm, true
+		}
+		return arg, true
+	}
+	urls := c.connectedRelayURLs()
+	if idx < 1 || idx > len(urls) {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid index: %d. Use /relay info to see the list.", idx)}
+		return "", false
+	}
+	return urls[idx-1], true
+}
+
+// formatRelayInfo renders the interesting fields of a NIP-11 document.
+func formatRelayInfo(url string, info nip11.RelayInformationDocument) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Relay %s:\n", url))
+	field := func(name, value string) {
+		if value != "" {
+			builder.WriteString(fmt.Sprintf("- %s: %s\n", name, sanitizeString(value)))
+		}
+	}
+
+	field("Name", info.Name)
+	field("Description", info.Description)
+	field("Software", strings.TrimSpace(info.Software+" "+info.Version))
+	field("Contact", info.Contact)
+
+	if len(info.SupportedNIPs) > 0 {
+		nips := make([]string, 0, len(info.SupportedNIPs))
+		for _, n := range info.SupportedNIPs {
+			nips = append(nips, fmt.Sprint(n))
+		}
+		field("Supported NIPs", strings.Join(nips, ", "))
+	}
+
+	if l := info.Limitation; l != nil {
+		if l.MinPowDifficulty > 0 {
+			field("Min PoW", strconv.Itoa(l.MinPowDifficulty))
+		}
+		if l.MaxMessageLength > 0 {
+			field("Max message length", strconv.Itoa(l.MaxMessageLength))
+		}
+		if l.AuthRequired {
+			field("Auth required", "yes")
+		}
+		if l.PaymentRequired {
+			field("Payment required", "yes")
+		}
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
@@ -3,6 +3,8 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,7 +19,7 @@ const (
 	maxDiscoveryDepth    = 2
 	maxActiveDiscoveries = 10
 	discoveryKind        = 10002
-	connectTimeout       = 10 * time.Second
+	defaultConnTimeout   = 10 // seconds
 	verifyTimeout        = 5 * time.Second
 	debounceDelay        = 60 * time.Second
 )
@@ -77,6 +79,58 @@ func (c *client) saveDiscoveredRelayStore() error {
 	return os.Rename(tmpPath, s.Path)
 }
 
+// failedRelay is an entry of failed_relays.json.
+type failedRelay struct {
+	URL      string `json:"url"`
+	FailedAt int64  `json:"failed_at"`
+}
+
+// loadFailCache restores unexpired fail cache entries saved by a previous run.
+func (c *client) loadFailCache() {
+	appConfigDir, err := getAppConfigDir()
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(appConfigDir, "failed_relays.json"))
+	if err != nil {
+		return
+	}
+	var tmp struct {
+		Failed []failedRelay `json:"failed"`
+	}
+	if json.Unmarshal(data, &tmp) != nil {
+		return
+	}
+
+	ttl := c.failCacheTTL()
+	for _, r := range tmp.Failed {
+		if time.Since(time.Unix(r.FailedAt, 0)) <= ttl {
+			c.verifyFailCache.Add(r.URL, r.FailedAt)
+		}
+	}
+}
+
+// saveFailCache writes the unexpired fail cache entries next to relays.json.
+func (c *client) saveFailCache() error {
+	appConfigDir, err := getAppConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(appConfigDir, "failed_relays.json")
+
+	ttl := c.failCacheTTL()
+	list := make([]failedRelay, 0, c.verifyFailCache.Len())
+	for _, url := range c.verifyFailCache.Keys() {
+		failedAt, ok := c.verifyFailCache.Peek(url)
+		if ok && time.Since(time.Unix(failedAt, 0)) <= ttl {
+			list = append(list, failedRelay{URL: url, FailedAt: failedAt})
+		}
+	}
+
+	data, _ := json.MarshalIndent(map[string]any{"failed": list}, "", "  ")
+	return writeFileAtomic(path, data, 0600)
+}
+
 func (c *client) getDiscoveredRelayURLs() []string {
 	c.discoveredStore.mu.RLock()
 	defer c.discoveredStore.mu.RUnlock()
@@ -91,6 +145,9 @@ func (c *client) getDiscoveredRelayURLs() []string {
 // Discovery logic
 
 func (c *client) discoverRelays(anchors []string, depth int) {
+	if c.config.DisableDiscovery {
+		return
+	}
 	for _, anchor := range anchors {
 		norm, err := normalizeRelayURL(anchor)
 		if err != nil {
@@ -118,15 +175,18 @@ func (c *client) discoverOnAnchor(anchorURL string, depth int) {
 	defer atomic.AddInt32(&c.activeDiscoveries, -1)
 
 	for {
-		// If client is shutting down, exit
+		// If client is shutting down or discovery was turned off, exit
 		select {
 		case <-c.ctx.Done():
 			return
 		default:
 		}
+		if c.config.DisableDiscovery {
+			return
+		}
 
 		// connection with a short timeout
-		connectCtx, cancelConnect := context.WithTimeout(c.ctx, connectTimeout)
+		connectCtx, cancelConnect := context.WithTimeout(c.ctx, c.connectTimeout())
 		relay, err := nostr.RelayConnect(connectCtx, anchorURL)
 		cancelConnect()
 		if err != nil {
@@ -160,6 +220,12 @@ func (c *client) discoverOnAnchor(anchorURL string, depth int) {
 					goto retry // break inner loop, continue outer
 				}
 
+				if c.config.DisableDiscovery {
+					sub.Unsub()
+					relay.Close()
+					return
+				}
+
 				// Process async to avoid blocking the event feed
 				c.wg.Add(1)
 				go func(e *nostr.Event) {
@@ -177,7 +243,7 @@ func (c *client) discoverOnAnchor(anchorURL string, depth int) {
 // parseRelayEvent processes a kind=10002 event and asynchronously verifies
 // new relays. Verification is done in separate goroutines.
 func (c *client) parseRelayEvent(ev *nostr.Event, verifyTimeout time.Duration, depth int) {
-	if ev.Kind != discoveryKind {
+	if ev.Kind != discoveryKind || c.config.DisableDiscovery {
 		return
 	}
 
@@ -215,7 +281,7 @@ func (c *client) parseRelayEvent(ev *nostr.Event, verifyTimeout time.Duration, d
 		}
 
 		// if in fail-cache, skip
-		if c.verifyFailCache != nil && c.verifyFailCache.Contains(url) {
+		if c.failCacheContains(url) {
 			continue
 		}
 
@@ -259,9 +325,7 @@ func (c *client) parseRelayEvent(ev *nostr.Event, verifyTimeout time.Duration, d
 			ok := c.verifyRelay(url, verifyTimeout)
 			if !ok {
 				// add to fail-cache
-				if c.verifyFailCache != nil {
-					c.verifyFailCache.Add(url, true)
-				}
+				c.failCacheAdd(url)
 				return
 			}
 
@@ -378,7 +442,7 @@ func (c *client) relayFailed(url string) bool {
 	if err != nil {
 		return false
 	}
-	return c.verifyFailCache.Contains(norm)
+	return c.failCacheContains(norm)
 }
 
 // markRelayFailed adds a discovered relay to the fail cache.
@@ -390,5 +454,116 @@ func (c *client) markRelayFailed(url string) {
 	if err != nil {
 		return
 	}
-	c.verifyFailCache.Add(norm, true)
+	c.failCacheAdd(norm)
+}
+
+// failCacheTTL returns how long a relay stays in the fail cache.
+func (c *client) failCacheTTL() time.Duration {
+	if c.config.FailCacheTTL != "" {
+		if ttl, err := time.ParseDuration(c.config.FailCacheTTL); err == nil && ttl > 0 {
+			return ttl
+		}
+	}
+	return defaultFailCacheTTL
+}
+
+// failCacheAdd records a failure of a normalized relay URL.
+func (c *client) failCacheAdd(norm string) {
+	if c.verifyFailCache == nil {
+		return
+	}
+	c.verifyFailCache.Add(norm, time.Now().Unix())
+}
+
+// failCacheContains reports whether a normalized relay URL failed within the TTL.
+// Expired entries are evicted.
+func (c *client) failCacheContains(norm string) bool {
+	if c.verifyFailCache == nil {
+		return false
+	}
+	failedAt, ok := c.verifyFailCache.Peek(norm)
+	if !ok {
+		return false
+	}
+	if time.Since(time.Unix(failedAt, 0)) > c.failCacheTTL() {
+		c.verifyFailCache.Remove(norm)
+		return false
+	}
+	return true
+}
+
+// Own relay list (NIP-65)
+
+// publishRelayList signs a kind 10002 event listing our anchor relays with
+// their read/write markers and publishes it to every anchor.
+func (c *client) publishRelayList() {
+	if len(c.config.AnchorRelays) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "No anchor relays set. Use /relay <url> to add one."}
+		return
+	}
+
+	tags := make(nostr.Tags, 0, len(c.config.AnchorRelays))
+	for _, url := range c.config.AnchorRelays {
+		if intent := c.anchorIntent(url); intent != anchorBoth {
+			tags = append(tags, nostr.Tag{"r", url, intent})
+		} else {
+			tags = append(tags, nostr.Tag{"r", url})
+		}
+	}
+	ev := nostr.Event{
+		PubKey:    c.pk,
+		CreatedAt: nostr.Now(),
+		Kind:      discoveryKind,
+		Tags:      tags,
+	}
+	if err := ev.Sign(c.sk); err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Failed to sign relay list: %v", err)}
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		ok     atomic.Int32
+		failed []string
+		mu     sync.Mutex
+	)
+	for _, url := range c.config.AnchorRelays {
+		wg.Go(func() {
+			if err := c.publishTo(url, ev); err != nil {
+				log.Printf("Could not publish relay list to %s: %v", url, err)
+				mu.Lock()
+				failed = append(failed, url)
+				mu.Unlock()
+				return
+			}
+			ok.Add(1)
+		})
+	}
+	wg.Wait()
+
+	msg := fmt.Sprintf("Published relay list (%d relays) to %d/%d anchors.", len(tags), ok.Load(), len(c.config.AnchorRelays))
+	if len(failed) > 0 {
+		msg += " Failed: " + strings.Join(failed, ", ")
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: msg}
+}
+
+// publishTo sends ev to url, over the existing connection if there is one,
+// otherwise over a short-lived connection of its own.
+func (c *client) publishTo(url string, ev nostr.Event) error {
+	c.relaysMu.Lock()
+	mr, connected := c.relays[url]
+	c.relaysMu.Unlock()
+	if connected {
+		return mr.relay.Publish(c.ctx, ev)
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.connectTimeout())
+	defer cancel()
+	relay, err := nostr.RelayConnect(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer relay.Close()
+	return relay.Publish(ctx, ev)
 }
@@ -3,9 +3,12 @@ package client
 import (
 	"fmt"
 	"log"
+	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/mmcloughlin/geohash"
@@ -26,6 +29,14 @@ func (c *client) joinChats(payload string) {
 
 outer:
 	for _, name := range chatNames {
+		if strings.HasPrefix(name, "geo:") {
+			gh, err := geoURIToGeohash(name)
+			if err != nil {
+				c.eventsChan <- DisplayEvent{Type: "ERROR", Content: err.Error()}
+				continue outer
+			}
+			name = gh
+		}
 		if geohash.Validate(name) != nil {
 			normalizedName, err := normalizeAndValidateChatName(name)
 			if err != nil {
@@ -73,6 +84,64 @@ outer:
 	}
 }
 
+// joinNear joins the geochat around the location in STRCHAT_LOCATION ("lat,lon").
+func (c *client) joinNear(payload string) {
+	loc := strings.TrimSpace(os.Getenv(locationEnv))
+	if loc == "" {
+		c.eventsChan <- DisplayEvent{
+			Type:    "ERROR",
+			Content: fmt.Sprintf("Set %s=<lat>,<lon> to use /near, or join with /join geo:<lat>,<lon>.", locationEnv),
+		}
+		return
+	}
+	uri := "geo:" + loc
+	if p := strings.TrimSpace(payload); p != "" {
+		uri += "," + p
+	}
+	c.joinChats(uri)
+}
+
+// zoomChat joins and activates the parent ("out") or child ("in") geohash of
+// the active geochat. The child is the cell containing the current center.
+func (c *client) zoomChat(payload string) {
+	active := c.getActiveView()
+	if active == nil || active.IsGroup || geohash.Validate(active.Name) != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Zoom works only when a geohash chat is active."}
+		return
+	}
+
+	hash := active.Name
+	var target string
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "out":
+		if len(hash) <= 1 {
+			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Already at the widest precision (1)."}
+			return
+		}
+		target = hash[:len(hash)-1]
+	case "in":
+		if len(hash) >= maxGeoPrecision {
+			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Already at the narrowest precision (%d).", maxGeoPrecision)}
+			return
+		}
+		lat, lng := geohash.DecodeCenter(hash)
+		target = geohash.EncodeWithPrecision(lat, lng, uint(len(hash)+1))
+	default:
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /zoom in|out"}
+		return
+	}
+
+	for _, v := range c.config.Views {
+		if !v.IsGroup && v.Name == target {
+			c.setActiveView(target)
+			c.flushAllOrdering()
+			c.updateAllSubscriptions()
+			return
+		}
+	}
+	c.joinChats(target)
+}
+
 func (c *client) createGroup(payload string) {
 	existingChats := make(map[string]struct{})
 	for _, view := range c.config.Views {
@@ -128,6 +197,13 @@ func (c *client) createGroup(payload string) {
 		}
 	}
 
+	// A DM view put in a group is one the user keeps.
+	for i := range c.config.Views {
+		if slices.Contains(validMembers, c.config.Views[i].Name) {
+			c.config.Views[i].Transient = false
+		}
+	}
+
 	newView := View{Name: name, IsGroup: true, Children: validMembers}
 	c.config.Views = append(c.config.Views, newView)
 	c.config.ActiveViewName = name
@@ -138,13 +214,29 @@ func (c *client) createGroup(payload string) {
 }
 
 func (c *client) leaveChat(chatName string) {
+	removed := removedView{removedAt: time.Now()}
+	found := false
 	var newViews []View
 	for _, view := range c.config.Views {
 		if !view.IsGroup && view.Name == chatName {
+			removed.view = view
+			found = true
 			continue
 		}
+		if view.IsGroup && slices.Contains(view.Children, chatName) {
+			removed.groups = append(removed.groups, cloneView(view))
+		}
 		newViews = append(newViews, view)
 	}
+	if found {
+		if s, ok := c.chatKeys[chatName]; ok {
+			removed.session = &s
+		}
+		if id, ok := c.config.ChatIdentities[chatName]; ok {
+			removed.identity = &id
+		}
+		c.pushUndo(removed)
+	}
 
 	finalViews := make([]View, 0, len(newViews))
 	for _, view := range newViews {
@@ -171,6 +263,8 @@ func (c *client) leaveChat(chatName string) {
 	if c.config.ActiveViewName == chatName {
 		c.config.ActiveViewName = ""
 	}
+	delete(c.config.ChatIdentities, chatName)
+	c.forgetGeoRelays(chatName)
 	c.saveConfig()
 	c.sendStateUpdate()
 	c.updateAllSubscriptions()
@@ -183,6 +277,8 @@ func (c *client) deleteGroup(groupName string) {
 	for _, view := range c.config.Views {
 		if view.Name != groupName {
 			newViews = append(newViews, view)
+		} else {
+			c.pushUndo(removedView{view: cloneView(view), removedAt: time.Now()})
 		}
 	}
 	c.config.Views = newViews
@@ -194,6 +290,79 @@ func (c *client) deleteGroup(groupName string) {
 	c.updateAllSubscriptions()
 }
 
+// pushUndo records a removed view, dropping expired entries and the oldest
+// ones beyond undoStackSize.
+func (c *client) pushUndo(r removedView) {
+	c.pruneUndo()
+	c.undoStack = append(c.undoStack, r)
+	if len(c.undoStack) > undoStackSize {
+		c.undoStack = c.undoStack[len(c.undoStack)-undoStackSize:]
+	}
+}
+
+func (c *client) pruneUndo() {
+	kept := c.undoStack[:0]
+	for _, r := range c.undoStack {
+		if time.Since(r.removedAt) <= undoTTL {
+			kept = append(kept, r)
+		}
+	}
+	c.undoStack = kept
+}
+
+// undoRemove restores the most recently left chat or deleted group and
+// activates it. A restored chat rejoins the groups it was removed from.
+func (c *client) undoRemove() {
+	c.pruneUndo()
+	if len(c.undoStack) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Nothing to undo. Removed chats can be restored for %s.", undoTTL)}
+		return
+	}
+	r := c.undoStack[len(c.undoStack)-1]
+	c.undoStack = c.undoStack[:len(c.undoStack)-1]
+
+	name := r.view.Name
+	if slices.ContainsFunc(c.config.Views, func(v View) bool { return v.Name == name }) {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Cannot restore '%s': it already exists.", name)}
+		return
+	}
+
+	c.config.Views = append(c.config.Views, r.view)
+	for _, g := range r.groups {
+		i := slices.IndexFunc(c.config.Views, func(v View) bool { return v.Name == g.Name })
+		if i < 0 {
+			c.config.Views = append(c.config.Views, g)
+		} else if c.config.Views[i].IsGroup && !slices.Contains(c.config.Views[i].Children, name) {
+			c.config.Views[i].Children = append(c.config.Views[i].Children, name)
+		}
+	}
+	if r.session != nil {
+		c.chatKeys[name] = *r.session
+	}
+	if r.identity != nil {
+		if c.config.ChatIdentities == nil {
+			c.config.ChatIdentities = make(map[string]chatIdentity)
+		}
+		c.config.ChatIdentities[name] = *r.identity
+	}
+
+	c.config.ActiveViewName = name
+	c.saveConfig()
+	c.sendStateUpdate()
+	c.updateAllSubscriptions()
+
+	kind := "chat"
+	if r.view.IsGroup {
+		kind = "group"
+	}
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Restored %s '%s'.", kind, name)}
+}
+
+func cloneView(v View) View {
+	v.Children = slices.Clone(v.Children)
+	return v
+}
+
 func (c *client) deleteView(viewName string) {
 	if viewName == "" {
 		activeView := c.getActiveView()
@@ -230,6 +399,10 @@ func (c *client) deleteView(viewName string) {
 
 func (c *client) setNick(nick string) {
 	nick = strings.TrimSpace(nick)
+	if rest, ok := strings.CutPrefix(nick, "--chat"); ok {
+		c.setChatNick(rest)
+		return
+	}
 	c.config.Nick = nick
 
 	if nick != "" {
@@ -239,16 +412,24 @@ func (c *client) setNick(nick string) {
 			Content: fmt.Sprintf("Nick set to: %s", c.n),
 		}
 		for name, session := range c.chatKeys {
+			if c.config.ChatNicks[name] != "" {
+				continue
+			}
 			session.nick = c.n
 			session.customNick = true
 			c.chatKeys[name] = session
+			c.saveChatIdentity(name)
 		}
 	} else {
 		c.n = npubToTokiPona(c.pk)
 		for name, session := range c.chatKeys {
+			if c.config.ChatNicks[name] != "" {
+				continue
+			}
 			session.nick = npubToTokiPona(session.pubKey)
 			session.customNick = false
 			c.chatKeys[name] = session
+			c.saveChatIdentity(name)
 		}
 		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Nick has been cleared."}
 	}
@@ -257,6 +438,154 @@ func (c *client) setNick(nick string) {
 	c.sendStateUpdate()
 }
 
+// setChatNick handles /nick --chat [name] for the active chat and
+// /nick --chat=<chat> [name] for another one. It overrides the global nick
+// in that chat only; an empty name goes back to the global nick.
+func (c *client) setChatNick(args string) {
+	var chat, nick string
+	if rest, ok := strings.CutPrefix(args, "="); ok {
+		chat, nick, _ = strings.Cut(rest, " ")
+	} else if args == "" || args[0] == ' ' {
+		if v := c.getActiveView(); v != nil && !v.IsGroup && v.DM == "" {
+			chat = v.Name
+		}
+		nick = args
+	} else {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /nick --chat [name] or /nick --chat=<chat> [name]"}
+		return
+	}
+	nick = strings.TrimSpace(nick)
+
+	valid := false
+	for _, v := range c.config.Views {
+		if v.Name == chat && chat != "" && !v.IsGroup && v.DM == "" {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "A per-chat nick needs a joined chat. Activate one or use /nick --chat=<chat> [name]."}
+		return
+	}
+
+	if nick == "" {
+		delete(c.config.ChatNicks, chat)
+	} else {
+		if c.config.ChatNicks == nil {
+			c.config.ChatNicks = make(map[string]string)
+		}
+		c.config.ChatNicks[chat] = nick
+	}
+
+	if session, ok := c.chatKeys[chat]; ok {
+		session.nick, session.customNick = c.sessionNick(chat, session.pubKey)
+		c.chatKeys[chat] = session
+		c.saveChatIdentity(chat)
+	}
+
+	c.saveConfig()
+	c.sendStateUpdate()
+	if nick != "" {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Nick in %s set to: %s", chat, nick)}
+	} else {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Nick in %s follows the global nick again.", chat)}
+	}
+}
+
+// sessionNick returns the nick of the session with pk in chat and whether
+// it was chosen by the user: the chat's own nick, the global one, or else
+// the toki pona name of pk.
+func (c *client) sessionNick(chat, pk string) (string, bool) {
+	if nick := c.config.ChatNicks[chat]; nick != "" {
+		return nick, true
+	}
+	if c.config.Nick != "" {
+		return c.config.Nick, true
+	}
+	return npubToTokiPona(pk), false
+}
+
+func (c *client) importKey(payload string) {
+	payload = strings.TrimSpace(payload)
+	prefix, value, err := nip19.Decode(payload)
+	if err != nil || prefix != "nsec" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Invalid key: expected a bech32-encoded nsec."}
+		return
+	}
+
+	sk, ok := value.(string)
+	if !ok {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Invalid key: could not decode nsec."}
+		return
+	}
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid key: %v", err)}
+		return
+	}
+
+	c.sk = sk
+	c.pk = pk
+	c.config.PrivateKey = sk
+
+	if c.config.Nick != "" {
+		c.n = c.config.Nick
+	} else {
+		c.n = npubToTokiPona(c.pk)
+	}
+	for name, session := range c.chatKeys {
+		if !session.customNick {
+			session.nick = npubToTokiPona(session.pubKey)
+			c.chatKeys[name] = session
+			c.saveChatIdentity(name)
+		}
+	}
+
+	c.saveConfig()
+	c.sendStateUpdate()
+
+	npub, _ := nip19.EncodePublicKey(pk)
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Imported main identity: %s (%s)", npub, c.n)}
+}
+
+func (c *client) exportKey(payload string) {
+	reveal := strings.TrimSpace(payload) == "--reveal-secret"
+
+	if c.sk == "" || c.pk == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "No main identity is loaded."}
+		return
+	}
+
+	npub, _ := nip19.EncodePublicKey(c.pk)
+
+	var builder strings.Builder
+	builder.WriteString("Main identity:\n")
+	builder.WriteString(fmt.Sprintf("Npub: %s\n", npub))
+	if reveal {
+		nsec, _ := nip19.EncodePrivateKey(c.sk)
+		builder.WriteString(fmt.Sprintf("Nsec: %s\n", nsec))
+	} else {
+		builder.WriteString("Nsec: (hidden, use /export --reveal-secret to show)\n")
+	}
+
+	if len(c.chatKeys) > 0 {
+		names := make([]string, 0, len(c.chatKeys))
+		for name := range c.chatKeys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		builder.WriteString("Chat identities:\n")
+		for _, name := range names {
+			session := c.chatKeys[name]
+			chatNpub, _ := nip19.EncodePublicKey(session.pubKey)
+			builder.WriteString(fmt.Sprintf(" - %s: %s (%s)\n", name, chatNpub, session.nick))
+		}
+	}
+
+	c.eventsChan <- DisplayEvent{Type: "INFO", Content: builder.String()}
+}
+
 func (c *client) setPoW(difficultyStr string) {
 	difficulty, err := strconv.Atoi(strings.TrimSpace(difficultyStr))
 	if err != nil {
@@ -292,6 +621,197 @@ func (c *client) setPoW(difficultyStr string) {
 	}
 }
 
+// setBroadcast reports or toggles whether messages sent from the active group
+// go to all of its chats: /broadcast [on|off].
+func (c *client) setBroadcast(payload string) {
+	activeView := c.getActiveView()
+	if activeView == nil || !activeView.IsGroup {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Broadcast is a group setting. Activate a group first."}
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "":
+		state := "off"
+		if activeView.AllowBroadcast {
+			state = "on"
+		}
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Broadcast for %s is %s.", activeView.Name, state)}
+		return
+	case "on":
+		activeView.AllowBroadcast = true
+	case "off":
+		activeView.AllowBroadcast = false
+	default:
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /broadcast [on|off]"}
+		return
+	}
+
+	c.saveConfig()
+	c.sendStateUpdate()
+	if activeView.AllowBroadcast {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Broadcast enabled for %s. Messages go to all of its chats.", activeView.Name)}
+	} else {
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Broadcast disabled for %s.", activeView.Name)}
+	}
+}
+
+// setNotify reports or sets the notification level of the active chat/group.
+func (c *client) setNotify(level string) {
+	activeView := c.getActiveView()
+	if activeView == nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Cannot set notifications: no active chat/group."}
+		return
+	}
+
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == "" {
+		current := activeView.Notify
+		if current == "" {
+			current = NotifyMentions
+		}
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Notifications for %s: %s.", activeView.Name, current)}
+		return
+	}
+	if level != NotifyAll && level != NotifyMentions && level != NotifyNone {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid notification level: '%s'. Use all, mentions or none.", level)}
+		return
+	}
+
+	for i := range c.config.Views {
+		if c.config.Views[i].Name == activeView.Name {
+			c.config.Views[i].Notify = level
+			if level == NotifyMentions {
+				c.config.Views[i].Notify = ""
+			}
+			break
+		}
+	}
+
+	c.saveConfig()
+	c.sendStateUpdate()
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Notifications for %s set to %s.", activeView.Name, level)}
+}
+
+func (c *client) setTimestampFormat(layout string) {
+	layout = strings.TrimSpace(layout)
+	if layout == "" {
+		c.config.TimestampFormat = ""
+		c.saveConfig()
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Timestamp format reset to default (%s).", defaultTimestampFormat)}
+		return
+	}
+
+	// A layout without any Go reference-time elements formats to itself.
+	sample := time.Now().Format(layout)
+	if sample == layout {
+		c.eventsChan <- DisplayEvent{
+			Type:    "ERROR",
+			Content: fmt.Sprintf("Invalid timestamp format: '%s'. Use a Go time layout, e.g. '2006-01-02 15:04' or '3:04PM'.", layout),
+		}
+		return
+	}
+
+	c.config.TimestampFormat = layout
+	c.saveConfig()
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Timestamp format set to '%s' (e.g. %s).", layout, sample)}
+}
+
+// setLayout reports or sets the message layout: /layout [compact|expanded].
+func (c *client) setLayout(payload string) {
+	switch layout := strings.ToLower(strings.TrimSpace(payload)); layout {
+	case "":
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Message layout is %s.", c.messageLayout())}
+	case LayoutCompact, LayoutExpanded:
+		c.config.MessageLayout = layout
+		c.saveConfig()
+		c.sendStateUpdate()
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Message layout set to %s.", layout)}
+	default:
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /layout [compact|expanded]"}
+	}
+}
+
+// messageLayout returns the configured message layout, LayoutCompact unless
+// it is set to LayoutExpanded.
+func (c *client) messageLayout() string {
+	if c.config.MessageLayout == LayoutExpanded {
+		return LayoutExpanded
+	}
+	return LayoutCompact
+}
+
+func (c *client) setTheme(name string) {
+	c.config.Theme = strings.TrimSpace(name)
+	c.saveConfig()
+}
+
+// setGeoRelayCount sets how many geo relays geochats connect to, or reports it.
+func (c *client) setGeoRelayCount(payload string) {
+	arg := strings.TrimSpace(payload)
+	if arg == "" {
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Geochats use the %d closest geo relays.", c.geoRelayCount())}
+		return
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > maxGeoRelayCount {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Geo relay count must be a number between 1 and %d.", maxGeoRelayCount)}
+		return
+	}
+	c.config.GeoRelayCount = n
+	c.saveConfig()
+	c.eventsChan <- DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Geochats will use the %d closest geo relays.", n)}
+	go c.updateAllSubscriptions()
+}
+
+// setDiscovery turns relay auto-discovery on or off, or reports its state.
+func (c *client) setDiscovery(payload string) {
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "":
+		state := "on"
+		if c.config.DisableDiscovery {
+			state = "off"
+		}
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Relay discovery is %s.", state)}
+		return
+	case "off":
+		c.config.DisableDiscovery = true
+		c.saveConfig()
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Relay discovery disabled. Only anchor and geo relays will be used."}
+		go c.updateAllSubscriptions()
+	case "on":
+		c.config.DisableDiscovery = false
+		c.saveConfig()
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Relay discovery enabled."}
+		go func() {
+			c.updateAllSubscriptions()
+			c.discoverRelays(c.anchorsFor(anchorRead), 1)
+		}()
+	default:
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /discovery [on|off]"}
+	}
+}
+
+// setDebug reports or toggles debug mode for this session: /debug [on|off].
+func (c *client) setDebug(payload string) {
+	switch strings.ToLower(strings.TrimSpace(payload)) {
+	case "":
+		state := "off"
+		if c.debug.Load() {
+			state = "on"
+		}
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Debug mode is %s.", state)}
+	case "on":
+		c.debug.Store(true)
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Debug mode enabled. Dropped messages are shown greyed out with the reason."}
+	case "off":
+		c.debug.Store(false)
+		c.eventsChan <- DisplayEvent{Type: "STATUS", Content: "Debug mode disabled."}
+	default:
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /debug [on|off]"}
+	}
+}
+
 // Read-only & Completions
 
 func (c *client) listChats() {
@@ -312,6 +832,43 @@ func (c *client) listChats() {
 	c.eventsChan <- DisplayEvent{Type: "INFO", Content: builder.String()}
 }
 
+// showStats reports the runtime counters of the event pipeline, relays and caches.
+func (c *client) showStats() {
+	c.relaysMu.Lock()
+	connected := 0
+	for _, r := range c.relays {
+		r.mu.Lock()
+		if r.connected {
+			connected++
+		}
+		r.mu.Unlock()
+	}
+	total := len(c.relays)
+	c.relaysMu.Unlock()
+
+	discovered := 0
+	if s := c.discoveredStore; s != nil {
+		s.mu.RLock()
+		discovered = len(s.Relays)
+		s.mu.RUnlock()
+	}
+	c.seenCacheMu.Lock()
+	seen := c.seenCache.Len()
+	c.seenCacheMu.Unlock()
+
+	var builder strings.Builder
+	builder.WriteString("Stats:\n")
+	builder.WriteString(fmt.Sprintf(" - Events received: %d\n", c.stats.received.Load()))
+	builder.WriteString(fmt.Sprintf(" - Messages displayed: %d\n", c.stats.displayed.Load()))
+	builder.WriteString(fmt.Sprintf(" - Dropped: %d blocked, %d PoW, %d muted, %d filtered, %d flood\n",
+		c.stats.droppedBlock.Load(), c.stats.droppedPoW.Load(), c.stats.droppedMute.Load(),
+		c.stats.droppedFilter.Load(), c.stats.droppedFlood.Load()))
+	builder.WriteString(fmt.Sprintf(" - Messages sent: %d\n", c.stats.sent.Load()))
+	builder.WriteString(fmt.Sprintf(" - Relays: %d/%d connected, %d discovered\n", connected, total, discovered))
+	builder.WriteString(fmt.Sprintf(" - Caches: %d/%d seen events, %d/%d users", seen, seenCacheSize, c.userContext.Len(), userContextCacheSize))
+	c.eventsChan <- DisplayEvent{Type: "INFO", Content: builder.String()}
+}
+
 func (c *client) getActiveChat() {
 	activeView := c.getActiveView()
 	var content string
@@ -323,21 +880,122 @@ func (c *client) getActiveChat() {
 	c.eventsChan <- DisplayEvent{Type: "INFO", Content: content}
 }
 
+func (c *client) whois(payload string) {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "Usage: /whois <@nick#abcd|num|npub>"}
+		return
+	}
+
+	var matches []string
+	if pk, ok := parsePubKey(payload); ok {
+		if _, known := c.userContext.Peek(pk); !known {
+			npub, _ := nip19.EncodePublicKey(pk)
+			c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Whois %s:\nPubkey: %s\nNpub: %s", npubToTokiPona(pk), pk, npub)}
+			return
+		}
+		matches = []string{pk}
+	} else if num, err := strconv.Atoi(payload); err == nil {
+		if num < 1 || num > len(c.lastWhoisMatches) {
+			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid number: %d. Use '/whois <@nick>' to list matches first.", num)}
+			return
+		}
+		matches = []string{c.lastWhoisMatches[num-1]}
+	} else {
+		if !strings.HasPrefix(payload, "@") {
+			payload = "@" + payload
+		}
+		for _, pk := range c.userContext.Keys() {
+			if ctx, ok := c.userContext.Get(pk); ok {
+				userIdentifier := fmt.Sprintf("@%s#%s", ctx.nick, ctx.shortPubKey)
+				if strings.HasPrefix(userIdentifier, payload) {
+					matches = append(matches, pk)
+				}
+			}
+		}
+		sort.Strings(matches)
+	}
+
+	if len(matches) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not find a known user matching '%s'.", payload)}
+		return
+	}
+
+	if len(matches) > 1 {
+		c.lastWhoisMatches = matches
+		var builder strings.Builder
+		builder.WriteString("Multiple users match, use /whois <num>:\n")
+		for i, pk := range matches {
+			ctx, _ := c.userContext.Peek(pk)
+			builder.WriteString(fmt.Sprintf("[%d] %s#%s (%s)\n", i+1, ctx.nick, ctx.shortPubKey, ctx.chat))
+		}
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: builder.String()}
+		return
+	}
+
+	pk := matches[0]
+	ctx, ok := c.userContext.Peek(pk)
+	if !ok {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "User is no longer in the cache."}
+		return
+	}
+	npub, _ := nip19.EncodePublicKey(pk)
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Whois %s#%s:\n", ctx.nick, ctx.shortPubKey))
+	builder.WriteString(fmt.Sprintf("Pubkey: %s\n", pk))
+	builder.WriteString(fmt.Sprintf("Npub: %s\n", npub))
+	builder.WriteString(fmt.Sprintf("Short pubkey: %s\n", ctx.shortPubKey))
+	builder.WriteString(fmt.Sprintf("Last seen in: %s", ctx.chat))
+	c.eventsChan <- DisplayEvent{Type: "INFO", Content: builder.String()}
+}
+
 func (c *client) getHelp() {
 	helpText := "COMMANDS:\n" +
-		"* /join <chat1> [chat2]... - Joins one or more chats. (Alias: /j)\n" +
+		"* /join <chat1> [chat2]... - Joins one or more chats. geo:<lat>,<lon>[,<precision>] joins a geochat. (Alias: /j)\n" +
+		"* /near [precision] - Joins the geochat around $STRCHAT_LOCATION (lat,lon).\n" +
+		"* /zoom in|out - Moves the active geochat to a narrower or wider precision (stays joined to the old one).\n" +
 		"* /set [name|names...] - Without args: shows active chat. With one name: activates a chat/group. With multiple names: creates a group. (Alias: /s)\n" +
 		"* /list - Lists all your chats and groups. (Alias: /l)\n" +
+		"* /history [count] - Loads up to count stored messages for the active chat/group (default 50).\n" +
 		"* /del [name] - Deletes a chat/group. If no name, deletes the active chat/group. (Alias: /d)\n" +
+		"* /undo - Restores the most recently left chat or deleted group (up to 5, for 5 minutes).\n" +
 		"* /nick [new_nick] - Sets or clears your nickname. (Alias: /n)\n" +
-		"* /pow [number] - Sets Proof-of-Work difficulty for the active chat/group. 0 to disable. (Alias: /p)\n" +
-		"* /relay [<num>|url1...] - List, remove (#), or add anchor relays. (Alias: /r)\n" +
-		"* /block [@nick] - Blocks a user. Without nick, lists blocked users. (Alias: /b)\n" +
+		"* /nick --chat[=<chat>] [new_nick] - Sets or clears your nickname in the active (or given) chat only.\n" +
+		"* /import <nsec> - Replaces your main identity with an existing nsec.\n" +
+		"* /export [--reveal-secret] - Shows your npub and chat identities. The nsec is shown only with --reveal-secret.\n" +
+		"* /pow [number] - Sets Proof-of-Work difficulty for the active chat/group. 0 to disable. Esc cancels mining. (Alias: /p)\n" +
+		"* /dnd [on|off|duration] - Toggles do not disturb, silencing all bells and mention markers. A duration like 30m turns it off again.\n" +
+		"* /broadcast [on|off] - Lets messages sent from the active group go to all of its chats. Off by default.\n" +
+		"* /notify [all|mentions|none] - Sets notifications for the active chat/group: bell on every message, on mentions (default), or none with no unread count.\n" +
+		"* /export-chat <path> - Writes the messages shown for the active chat/group to a text file, or JSON if the path ends in .json.\n" +
+		"* /debug [on|off] - Shows messages hidden by PoW, mutes, filters or flood control greyed out with the reason, for this session.\n" +
+		"* /stats - Shows counters of received, displayed, dropped and sent messages, relays and caches.\n" +
+		"* /who [minutes] - Lists who wrote in the active chat/group recently, newest first. Defaults to 15 minutes.\n" +
+		"* /log [on|off] - Turns logging of chat messages to disk on/off. Without args, shows where logs are written.\n" +
+		"* /layout [compact|expanded] - Shows messages on one line (default), or with a nick and time header above the content.\n" +
+		"* /timeformat [layout] - Sets the message timestamp format as a Go time layout (e.g. 2006-01-02 15:04). Without args, resets to 15:04:05.\n" +
+		"* /theme [name|reload] - Switches the color theme. Without args, lists available themes. 'reload' re-reads theme.json from the config dir.\n" +
+		"* /relay [<num>|url1... [read|write|both]] - List, remove (#), or add anchor relays. read/write limits an anchor to subscribing/publishing. (Alias: /r)\n" +
+		"* /relay info [<num>|url] - List connected relays or show a relay's NIP-11 info.\n" +
+		"* /relay drop|reconnect <num>|url - Disconnect a relay or force a fresh connection.\n" +
+		"* /relay publish - Publishes your anchor relays as a NIP-65 relay list (kind 10002).\n" +
+		"* /georelays [n] - Show or set how many geo relays geochats use (1-20).\n" +
+		"* /discovery [on|off] - Show or toggle relay auto-discovery.\n" +
+		"* /block [@nick|npub|pubkey] - Blocks a user. Without nick, lists blocked users. (Alias: /b)\n" +
+		"* /block export|import <path> - Saves the block list to a JSON file, or merges one into it.\n" +
 		"* /unblock [<num>|@nick|pubkey] - Unblocks a user. Without args, lists blocked users. (Alias: /ub)\n" +
-		"* /filter [word|regex|<num>] - Adds a filter. Without args, lists filters. With number, toggles off/on. (Alias: /f)\n" +
+		"* /reply <id> <message> - Replies to a recent message by the id shown next to it, threading it with NIP-10 tags. (Alias: /re)\n" +
+		"* /dm [on|off] - Shows or toggles DM mode. While on, @nick messages are sent as encrypted NIP-17 DMs and incoming DMs open a dm: view.\n" +
+		"* /dm <@nick|npub|pubkey> [message] - Opens a DM view with a user and optionally sends a message. Messages typed in a dm: view are encrypted.\n" +
+		"* /whois <@nick|num> - Shows pubkey, npub and last chat of a known user. (Alias: /w)\n" +
+		"* /filter [@chat] [word|regex|<num>] - Adds a filter, optionally only for one chat. Append :i (ignore case) and/or :w (whole word) to a word, or i/w after a /regex/. Prefix with nick: to match nicks with a regex, e.g. nick:bot-.* Without args, lists filters. With number, toggles off/on. (Alias: /f)\n" +
 		"* /unfilter [<num>] - Removes a filter by number. Without args, clears all. (Alias: /uf)\n" +
-		"* /mute [word|regex|<num>] - Adds a mute. Without args, lists mutes. With number, toggles off/on. (Alias: /m)\n" +
+		"* /mute [@chat] [word|regex|<num>] [duration] - Adds a mute, optionally only for one chat or for a duration like 1h. Takes the same flags as /filter. Without args, lists mutes. With number, toggles off/on. (Alias: /m)\n" +
 		"* /unmute [<num>] - Removes a mute by number. Without args, clears all. (Alias: /um)\n" +
+		"* /highlight [@chat] [word|regex|<num>] - Highlights matching messages without hiding others. Takes the same flags as /filter; nick: patterns highlight the whole message. Without args, lists highlights. With number, toggles off/on. (Alias: /hl)\n" +
+		"* /unhighlight [<num>] - Removes a highlight by number. Without args, clears all. (Alias: /uhl)\n" +
+		"* /version - Shows the version, commit and build date of this client.\n" +
 		"* /quit - Exits the application. (Alias: /q)"
 
 	c.eventsChan <- DisplayEvent{Type: "INFO", Content: helpText}
@@ -353,18 +1011,11 @@ func (c *client) handleNickCompletion(prefix string) {
 		return
 	}
 
-	relevantChats := make(map[string]struct{})
-	if activeView.IsGroup {
-		for _, child := range activeView.Children {
-			relevantChats[child] = struct{}{}
-		}
-	} else {
-		relevantChats[activeView.Name] = struct{}{}
-	}
+	relevantChats := viewChats(activeView)
 
 	for _, key := range c.userContext.Keys() {
 		if value, ok := c.userContext.Get(key); ok {
-			if _, isActiveChat := relevantChats[value.chat]; isActiveChat {
+			if _, isActiveChat := relevantChats[value.chat]; isActiveChat && c.userFresh(value) {
 				if strings.HasPrefix(value.nick, prefix) {
 					entries = append(entries, fmt.Sprintf("@%s#%s ", value.nick, value.shortPubKey))
 				}
@@ -380,6 +1031,101 @@ func (c *client) handleNickCompletion(prefix string) {
 	c.eventsChan <- DisplayEvent{Type: "NICK_COMPLETION_RESULT", Payload: entries}
 }
 
+// listPresent lists the users seen in the active chat/group within the last
+// minutes (defaultWhoWindow if empty), most recent first.
+func (c *client) listPresent(payload string) {
+	window := defaultWhoWindow
+	if p := strings.TrimSpace(payload); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			c.eventsChan <- DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid number of minutes: '%s'.", p)}
+			return
+		}
+		window = n
+	}
+
+	activeView := c.getActiveView()
+	if activeView == nil {
+		c.eventsChan <- DisplayEvent{Type: "ERROR", Content: "No active chat/group."}
+		return
+	}
+	relevantChats := viewChats(activeView)
+
+	since := time.Now().Add(-time.Duration(window) * time.Minute)
+	var present []userContext
+	for _, key := range c.userContext.Keys() {
+		if value, ok := c.userContext.Peek(key); ok && value.lastSeen.After(since) && c.userFresh(value) {
+			if _, isActiveChat := relevantChats[value.chat]; isActiveChat {
+				present = append(present, value)
+			}
+		}
+	}
+
+	if len(present) == 0 {
+		c.eventsChan <- DisplayEvent{Type: "INFO", Content: fmt.Sprintf("Nobody seen in %s in the last %d min.", activeView.Name, window)}
+		return
+	}
+	sort.Slice(present, func(i, j int) bool { return present[i].lastSeen.After(present[j].lastSeen) })
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Seen in %s in the last %d min (%d):\n", activeView.Name, window, len(present)))
+	for _, u := range present {
+		ago := max(time.Since(u.lastSeen).Round(time.Minute), time.Minute)
+		chat := ""
+		if activeView.IsGroup {
+			chat = " in " + u.chat
+		}
+		builder.WriteString(fmt.Sprintf("- %s#%s%s (%s ago)\n", u.nick, u.shortPubKey, chat, strings.TrimSuffix(ago.String(), "0s")))
+	}
+	c.eventsChan <- DisplayEvent{Type: "INFO", Content: strings.TrimSuffix(builder.String(), "\n")}
+}
+
+// userContextTTL returns how long a user stays in nick completion and /who
+// after their last message.
+func (c *client) userContextTTL() time.Duration {
+	if c.config.UserContextTTL != "" {
+		if ttl, err := time.ParseDuration(c.config.UserContextTTL); err == nil && ttl > 0 {
+			return ttl
+		}
+	}
+	return defaultUserContextTTL
+}
+
+func (c *client) userFresh(u userContext) bool {
+	return time.Since(u.lastSeen) <= c.userContextTTL()
+}
+
+// runUserContextSweep periodically evicts users not seen within the TTL.
+func (c *client) runUserContextSweep() {
+	ticker := time.NewTicker(userContextSweepPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, key := range c.userContext.Keys() {
+				if value, ok := c.userContext.Peek(key); ok && !c.userFresh(value) {
+					c.userContext.Remove(key)
+				}
+			}
+		}
+	}
+}
+
+// viewChats returns the set of chats a view covers.
+func viewChats(v *View) map[string]struct{} {
+	chats := make(map[string]struct{})
+	if v.IsGroup {
+		for _, child := range v.Children {
+			chats[child] = struct{}{}
+		}
+	} else {
+		chats[v.Name] = struct{}{}
+	}
+	return chats
+}
+
 // Core State Primitives
 
 func (c *client) setActiveView(name string) {
@@ -402,29 +1148,32 @@ func (c *client) setActiveView(name string) {
 	}
 
 	if !view.IsGroup {
-		sk := nostr.GeneratePrivateKey()
-		pk, _ := nostr.GetPublicKey(sk)
-
-		nick := c.config.Nick
-		custom := false
-		if nick == "" {
-			nick = npubToTokiPona(pk)
+		if session, ok := c.chatKeys[name]; ok && c.config.PersistIdentities {
+			npub, _ := nip19.EncodePublicKey(session.pubKey)
+			c.eventsChan <- DisplayEvent{
+				Type: "STATUS",
+				Content: fmt.Sprintf("Restored identity for chat '%s': %s (%s)",
+					view.Name, npub, session.nick),
+			}
 		} else {
-			custom = true
-		}
-
-		c.chatKeys[name] = chatSession{
-			privKey:    sk,
-			pubKey:     pk,
-			nick:       nick,
-			customNick: custom,
-		}
+			sk := nostr.GeneratePrivateKey()
+			pk, _ := nostr.GetPublicKey(sk)
+			nick, custom := c.sessionNick(name, pk)
+
+			c.chatKeys[name] = chatSession{
+				privKey:    sk,
+				pubKey:     pk,
+				nick:       nick,
+				customNick: custom,
+			}
+			c.saveChatIdentity(name)
 
-		npub, _ := nip19.EncodePublicKey(pk)
-		c.eventsChan <- DisplayEvent{
-			Type: "STATUS",
-			Content: fmt.Sprintf("Generated ephemeral identity for chat '%s': %s (%s)",
-				view.Name, npub, nick),
+			npub, _ := nip19.EncodePublicKey(pk)
+			c.eventsChan <- DisplayEvent{
+				Type: "STATUS",
+				Content: fmt.Sprintf("Generated ephemeral identity for chat '%s': %s (%s)",
+					view.Name, npub, nick),
+			}
 		}
 	}
 
@@ -464,6 +1213,20 @@ func (c *client) sendStateUpdate() {
 		Views:           c.config.Views,
 		ActiveViewIndex: activeIdx,
 		Nick:            c.n,
+		BellOnMention:   c.config.BellOnMention,
+		DisableURLOpen:  c.config.DisableURLOpen,
+		Mouse:           c.config.Mouse,
+		Theme:           c.config.Theme,
+		Keybindings:     c.config.Keybindings,
+		ConfirmDeletes:  c.config.ConfirmDeletes,
+		NickColors:      c.config.NickColors,
+		OwnNickByPubKey: c.config.OwnNickByPubKey,
+		NoColor:         c.config.NoColor,
+		MaxMsgLen:       c.maxMsgLen(),
+		TruncateDisplay: max(c.config.TruncateDisplay, 0),
+		HangingIndent:   c.config.HangingIndent,
+		MessageLayout:   c.messageLayout(),
+		RelaySort:       c.config.RelaySort,
 	}
 
 	if len(c.config.Views) == 0 || activeIdx == -1 {
@@ -471,22 +1234,69 @@ func (c *client) sendStateUpdate() {
 		return
 	}
 
-	if c.config.Nick != "" {
+	state.PoW = c.effectivePoWForView(&c.config.Views[activeIdx])
+
+	if v := c.config.Views[activeIdx]; !v.IsGroup && c.config.ChatNicks[v.Name] != "" {
+		state.Nick = c.config.ChatNicks[v.Name]
+	} else if c.config.Nick != "" {
 		state.Nick = c.config.Nick
+	} else if s, ok := c.chatKeys[v.Name]; ok && !v.IsGroup && s.nick != "" {
+		state.Nick = s.nick
 	} else {
-		v := c.config.Views[activeIdx]
-		if v.IsGroup {
-			state.Nick = npubToTokiPona(c.pk)
-		} else if s, ok := c.chatKeys[v.Name]; ok && s.nick != "" {
-			state.Nick = s.nick
-		} else {
-			state.Nick = npubToTokiPona(c.pk)
-		}
+		state.Nick = npubToTokiPona(c.pk)
 	}
 
 	c.eventsChan <- DisplayEvent{Type: "STATE_UPDATE", Payload: state}
 }
 
+// loadChatIdentities seeds chatKeys from identities saved in the config.
+func (c *client) loadChatIdentities() {
+	if !c.config.PersistIdentities {
+		return
+	}
+	for name, id := range c.config.ChatIdentities {
+		pk, err := nostr.GetPublicKey(id.PrivateKey)
+		if err != nil || pk != id.PubKey {
+			log.Printf("Ignoring invalid saved identity for chat '%s'", name)
+			delete(c.config.ChatIdentities, name)
+			continue
+		}
+		nick := id.Nick
+		if nick == "" {
+			nick = npubToTokiPona(pk)
+		}
+		if chatNick := c.config.ChatNicks[name]; chatNick != "" {
+			nick = chatNick
+		}
+		c.chatKeys[name] = chatSession{
+			privKey:    id.PrivateKey,
+			pubKey:     pk,
+			nick:       nick,
+			customNick: c.config.Nick != "" || c.config.ChatNicks[name] != "",
+		}
+	}
+}
+
+// saveChatIdentity stores the chat's session in the config if persistence is enabled.
+// The caller is responsible for saving the config afterwards.
+func (c *client) saveChatIdentity(name string) {
+	if !c.config.PersistIdentities {
+		return
+	}
+	session, ok := c.chatKeys[name]
+	if !ok {
+		return
+	}
+	if c.config.ChatIdentities == nil {
+		c.config.ChatIdentities = make(map[string]chatIdentity)
+	}
+	c.config.ChatIdentities[name] = chatIdentity{
+		PrivateKey: session.privKey,
+		PubKey:     session.pubKey,
+		Nick:       session.nick,
+	}
+}
+
 func (c *client) saveConfig() {
 	if err := c.config.save(); err != nil {
 		log.Printf("Error saving config: %v", err)
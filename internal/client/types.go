@@ -3,6 +3,7 @@ package client
 import (
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nbd-wtf/go-nostr"
@@ -11,16 +12,113 @@ import (
 // Constants for the client's operation.
 const (
 	defaultRelayCount    = 5
+	maxGeoRelayCount     = 20
+	defaultGeoPrecision  = 6
+	maxGeoPrecision      = 12
 	geoChatKind          = 20000
 	ephChatKind          = 23333
 	seenCacheSize        = 8192
 	userContextCacheSize = 4096
-	MaxMsgLen            = 2000
+	recentEventsSize     = 2048
+	floodCacheSize       = 1024
+	dmRelayListsSize     = 256
+	internalActionsSize  = 64
+	DefaultMaxMsgLen     = 2000
+	minMsgLen            = 140
+	maxMsgLenLimit       = 32000
 	maxChatNameLen       = 12
 	orderingFlushDelay   = 200 * time.Millisecond
+	minOrderingDelay     = 10 * time.Millisecond
+	maxOrderingDelay     = 2 * time.Second
+	dmViewPrefix         = "dm:"  // ':' never appears in chat names
+	dmInboxChat          = "dm:*" // pseudo-chat subscribing to our gift wraps
+	giftWrapLookback     = 48 * time.Hour
+	dmRelayListTTL       = 10 * time.Minute
+	maxTransientDMViews  = 20
+	powProgressPeriod    = 250 * time.Millisecond // between POW_PROGRESS events
+	powNonceMask         = 1<<40 - 1              // keeps mined nonces to at most 13 digits
 	perStreamBufferMax   = 256
+
+	defaultTimestampFormat = "15:04:05"
+	defaultMaxClockSkew    = 300 // seconds
+	defaultHistoryLimit    = 50
+	historyTimeout         = 10 * time.Second
+	defaultMaxMsgsPerMin   = 30
+	defaultFloodWindow     = 60 // seconds
+	muteExpiryInterval     = 30 * time.Second
+	defaultPublishRetries  = 3
+	defaultPingInterval    = 60 // seconds
+	pingTimeout            = 5 * time.Second
+	defaultFailCacheTTL    = 6 * time.Hour
+	undoStackSize          = 5
+	undoTTL                = 5 * time.Minute
+	maxChatLogSize         = 10 << 20 // bytes
+	defaultWhoWindow       = 15       // minutes
+	defaultUserContextTTL  = time.Hour
+	userContextSweepPeriod = 5 * time.Minute
+)
+
+// Bounds of config.ShortPubKeyLen, the pubkey hex chars shown after a nick.
+const (
+	defaultShortPubKeyLen = 4
+	minShortPubKeyLen     = 4
+	maxShortPubKeyLen     = 16
+)
+
+// Intents of an anchor relay, stored in config.AnchorIntents.
+const (
+	anchorRead  = "read"
+	anchorWrite = "write"
+	anchorBoth  = "both"
+)
+
+// Notification levels of a view, stored in View.Notify. An empty value
+// means NotifyMentions.
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
 )
 
+// Message layouts of the Messages pane, stored in config.MessageLayout. An
+// empty value means LayoutCompact.
+const (
+	LayoutCompact  = "compact"  // one line: nick, content, then ID and time
+	LayoutExpanded = "expanded" // nick, ID and time header, content below
+)
+
+// Sources of a relay, sent in RelayInfo.Type.
+const (
+	RelayAnchor     = "anchor"
+	RelayGeo        = "geo"
+	RelayDiscovered = "discovered"
+	RelayDefault    = "default" // fallback used when a chat has no other relays
+)
+
+// Orders of the relay list in the Info pane, stored in config.RelaySort. An
+// empty value means RelaySortURL.
+const (
+	RelaySortURL     = "url"
+	RelaySortLatency = "latency"
+	RelaySortType    = "type"
+)
+
+// Delivery states of an own message, sent as the Content of MESSAGE_STATUS events.
+const (
+	MsgStatusPending = "pending"
+	MsgStatusSent    = "sent"
+	MsgStatusFailed  = "failed"
+)
+
+// Message fields a filter or mute can match against.
+const (
+	fieldContent = ""
+	fieldNick    = "nick"
+)
+
+// locationEnv holds "lat,lon" for /near.
+const locationEnv = "STRCHAT_LOCATION"
+
 // defaultEphChatRelays provides a fallback list of relays for named chats.
 var defaultEphChatRelays = []string{
 	"wss://relay.damus.io",
@@ -37,9 +135,12 @@ type UserAction struct {
 
 // RelayInfo holds status information about a single relay connection.
 type RelayInfo struct {
-	URL       string
-	Latency   time.Duration
-	Connected bool
+	URL          string
+	Latency      time.Duration
+	Connected    bool
+	ChatCount    int  // chats served by the relay's current subscription
+	ReceivedEOSE bool // stored events of the current subscription were delivered
+	Type         string
 }
 
 // DisplayEvent represents an event sent from the client to the TUI for display.
@@ -52,11 +153,64 @@ type DisplayEvent struct {
 	ShortPubKey  string
 	IsOwnMessage bool
 	RelayURL     string
-	ID           string
+	ID           string // short ID shown with the message
+	FullID       string
 	Chat         string
+	Skew         int64    // seconds between CreatedAt and local time, set only above the threshold
+	LocalID      string   // correlates an own message with its MESSAGE_STATUS updates
+	Highlights   [][2]int // byte ranges of Content matched by highlight patterns
+	RawContent   string   // original content when Content was rewritten for display, for copying
+	ReplyTo      string   // ID of the event this message replies to (NIP-10)
+	Dropped      string   // debug mode: why the message would otherwise have been hidden
 	Payload      any
 }
 
+// outboxItem is a signed event waiting to be delivered to a chat's relays.
+type outboxItem struct {
+	ev      nostr.Event
+	localID string
+	relays  []*managedRelay      // used for the first attempt; retries pick current relays
+	report  func(deliveryResult) // optional, called once delivery succeeds or gives up
+}
+
+// deliveryResult is how many relays accepted a message sent to chat, out of
+// how many were tried. total is 0 if the message never reached a relay.
+type deliveryResult struct {
+	chat     string
+	accepted int
+	total    int
+}
+
+// removedView is a left chat or deleted group that /undo can restore.
+type removedView struct {
+	view      View
+	groups    []View // groups that contained a left chat, before it was removed
+	session   *chatSession
+	identity  *chatIdentity
+	removedAt time.Time
+}
+
+// clientStats are the runtime counters shown by /stats.
+type clientStats struct {
+	received      atomic.Int64 // events handed to processEvent, duplicates included
+	displayed     atomic.Int64 // messages that passed every check
+	sent          atomic.Int64 // own events accepted by at least one relay
+	droppedBlock  atomic.Int64
+	droppedPoW    atomic.Int64
+	droppedMute   atomic.Int64
+	droppedFilter atomic.Int64
+	droppedFlood  atomic.Int64
+}
+
+// eventState is how far an event got towards the TUI, stored in seenCache.
+type eventState uint8
+
+const (
+	eventSeen    eventState = iota // received, not shown yet
+	eventQueued                    // waiting in an ordering buffer
+	eventFlushed                   // sent to the TUI, or our own and already shown
+)
+
 type orderItem struct {
 	ev        DisplayEvent
 	createdAt int64
@@ -68,6 +222,21 @@ type StateUpdate struct {
 	Views           []View
 	ActiveViewIndex int
 	Nick            string
+	BellOnMention   bool
+	DisableURLOpen  bool
+	Mouse           bool
+	Theme           string
+	Keybindings     map[string]string
+	ConfirmDeletes  bool
+	NickColors      string
+	OwnNickByPubKey bool
+	NoColor         bool
+	PoW             int // effective difficulty for the active view
+	MaxMsgLen       int // longest message, in characters, the input accepts
+	TruncateDisplay int // characters shown of long messages until expanded, 0 shows all
+	HangingIndent   bool
+	MessageLayout   string // LayoutCompact or LayoutExpanded
+	RelaySort       string // RelaySortURL, RelaySortLatency or RelaySortType
 }
 
 type chatSession struct {
@@ -82,6 +251,22 @@ type userContext struct {
 	nick        string
 	chat        string
 	shortPubKey string
+	lastSeen    time.Time // CreatedAt of the newest message seen from the user
+}
+
+// dmRelayList caches the DM relays (kind 10050) of a pubkey. urls is nil
+// if it has none.
+type dmRelayList struct {
+	urls      []string
+	fetchedAt time.Time
+}
+
+// recentEvent is a displayed event that /reply can reference by its short ID.
+type recentEvent struct {
+	id     string
+	root   string // NIP-10 root of the thread, empty if the event starts one
+	pubKey string
+	chat   string
 }
 
 // managedRelay wraps a nostr.Relay with additional state for management.
@@ -91,7 +276,11 @@ type managedRelay struct {
 	latency           time.Duration
 	subscription      *nostr.Subscription
 	connected         bool
+	receivedEOSE      bool            // Reset whenever the subscription is replaced
+	lastAlive         nostr.Timestamp // Latest time the subscription was known live; a resubscribe backfills from here
 	reconnectAttempts int
+	ephemeral         bool // dialed for a single publish and closed after delivery
+	writeOnly         bool // write-only anchor without a subscription, see watchWriteOnlyRelay
 	mu                sync.Mutex
 }
 
@@ -100,4 +289,17 @@ type compiledPattern struct {
 	raw     string
 	regex   *regexp.Regexp
 	literal string
+	chat    string
+	field   string
+	fold    bool // literal is lowercased and matched case-insensitively
+	word    bool // literal must be delimited by non-word characters
+}
+
+// tokenBucket is a simple rate limiter that refills continuously up to its capacity.
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+	mu       sync.Mutex
 }
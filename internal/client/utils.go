@@ -7,10 +7,12 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
+	"github.com/mmcloughlin/geohash"
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/rivo/uniseg"
+	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
 var hexToLeadingZeros [256]int
@@ -88,6 +90,50 @@ func parsePowHint(s string) (int, bool) {
 	return n, true
 }
 
+func newTokenBucket(perMinute int) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// take consumes a token if one is available. Otherwise it returns how long
+// the caller has to wait until the next token is available.
+func (b *tokenBucket) take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// parsePubKey returns the hex pubkey for a 64-char hex string or an npub.
+func parsePubKey(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "npub1") {
+		prefix, value, err := nip19.Decode(s)
+		if err != nil || prefix != "npub" {
+			return "", false
+		}
+		pk, ok := value.(string)
+		return pk, ok && nostr.IsValidPublicKey(pk)
+	}
+	pk := strings.ToLower(s)
+	if len(pk) == 64 && nostr.IsValidPublicKey(pk) {
+		return pk, true
+	}
+	return "", false
+}
+
 func safeSuffix(s string, n int) string {
 	if len(s) <= n {
 		return s
@@ -111,19 +157,29 @@ func sameStringSet(a, b []string) bool {
 	return true
 }
 
-func truncateString(s string, maxClusters int) string {
-	g := uniseg.NewGraphemes(s)
-	var b strings.Builder
-	count := 0
-	for g.Next() {
-		if count >= maxClusters {
-			b.WriteString("...")
-			break
+// geoURIToGeohash converts "geo:<lat>,<lon>[,<precision>]" to a geohash.
+func geoURIToGeohash(uri string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(uri, "geo:"), ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", fmt.Errorf("invalid location '%s': use geo:<lat>,<lon>[,<precision>]", uri)
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("invalid coordinates in '%s'", uri)
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return "", fmt.Errorf("coordinates out of range in '%s': latitude must be within ±90, longitude within ±180", uri)
+	}
+	precision := defaultGeoPrecision
+	if len(parts) == 3 {
+		p, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || p < 1 || p > maxGeoPrecision {
+			return "", fmt.Errorf("invalid precision in '%s': must be 1-%d", uri, maxGeoPrecision)
 		}
-		b.WriteString(g.Str())
-		count++
+		precision = p
 	}
-	return b.String()
+	return geohash.EncodeWithPrecision(lat, lon, uint(precision)), nil
 }
 
 func normalizeAndValidateChatName(name string) (string, error) {
@@ -162,6 +218,65 @@ func sanitizeString(s string) string {
 	return b.String()
 }
 
+var nostrEntityRe = regexp.MustCompile(`(?:nostr:)?\b(?:npub|nprofile|note|nevent|naddr)1[02-9ac-hj-np-z]+`)
+
+// renderNostrEntities shortens NIP-19 references in content: profiles become
+// "@npub1abcd…wxyz" and events or addresses "«note1abcd…wxyz»". Anything that
+// does not decode is left as is.
+func renderNostrEntities(s string) string {
+	return nostrEntityRe.ReplaceAllStringFunc(s, func(m string) string {
+		code := strings.TrimPrefix(m, "nostr:")
+		prefix, value, err := nip19.Decode(code)
+		if err != nil {
+			return m
+		}
+		switch prefix {
+		case "npub":
+			return "@" + shortEntity(code)
+		case "nprofile":
+			if pp, ok := value.(nostr.ProfilePointer); ok {
+				if npub, err := nip19.EncodePublicKey(pp.PublicKey); err == nil {
+					return "@" + shortEntity(npub)
+				}
+			}
+			return m
+		default:
+			return "«" + shortEntity(code) + "»"
+		}
+	})
+}
+
+// shortEntity abbreviates a bech32 string to its prefix, four data characters and the last four.
+func shortEntity(code string) string {
+	sep := strings.IndexByte(code, '1')
+	if sep < 0 || len(code) <= sep+9 {
+		return code
+	}
+	return code[:sep+5] + "…" + code[len(code)-4:]
+}
+
+var shortcodeRe = regexp.MustCompile(`:([a-zA-Z0-9_]+):`)
+
+// renderEmoji replaces ":shortcode:" with "‹shortcode›" for the shortcodes
+// declared by the event's NIP-30 emoji tags. Other shortcodes are left as is.
+func renderEmoji(content string, tags nostr.Tags) string {
+	declared := make(map[string]struct{})
+	for _, tag := range tags {
+		if len(tag) >= 3 && tag[0] == "emoji" {
+			declared[tag[1]] = struct{}{}
+		}
+	}
+	if len(declared) == 0 {
+		return content
+	}
+	return shortcodeRe.ReplaceAllStringFunc(content, func(m string) string {
+		if _, ok := declared[m[1:len(m)-1]]; ok {
+			return "‹" + m[1:len(m)-1] + "›"
+		}
+		return m
+	})
+}
+
 func normalizeRelayURL(raw string) (string, error) {
 	raw = strings.TrimSpace(raw)
 	raw = strings.TrimRight(raw, "/,.;")
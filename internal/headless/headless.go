@@ -0,0 +1,120 @@
+// Package headless is a front-end without a terminal UI: it reads actions
+// from an input stream and writes client events to an output stream, one
+// JSON object per line, for scripts and bridges.
+package headless
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+// maxLineSize is the longest input line accepted.
+const maxLineSize = 1 << 20
+
+type headless struct {
+	actionsChan chan<- client.UserAction
+	eventsChan  <-chan client.DisplayEvent
+	in          io.Reader
+	out         io.Writer
+	outMu       sync.Mutex
+}
+
+// New creates a headless front-end reading from in and writing to out.
+func New(actions chan<- client.UserAction, events <-chan client.DisplayEvent, in io.Reader, out io.Writer) *headless {
+	return &headless{
+		actionsChan: actions,
+		eventsChan:  events,
+		in:          in,
+		out:         out,
+	}
+}
+
+// Run forwards input lines as actions and events as output lines until the
+// client shuts down. A line holding a JSON object such as
+// {"type":"JOIN_CHATS","payload":"foo"} is sent as that action; any other
+// non-empty line is sent as a message to the active view. The end of the
+// input quits the client.
+func (h *headless) Run() error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.writeEvents()
+	}()
+
+	scanner := bufio.NewScanner(h.in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		action, ok, err := parseLine(scanner.Text())
+		if err != nil {
+			h.write(client.DisplayEvent{Type: "ERROR", Content: err.Error()})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		select {
+		case h.actionsChan <- action:
+		case <-done:
+			return nil
+		}
+		if action.Type == "QUIT" {
+			<-done
+			return nil
+		}
+	}
+	err := scanner.Err()
+
+	select {
+	case h.actionsChan <- client.UserAction{Type: "QUIT"}:
+		<-done
+	case <-done:
+	}
+	return err
+}
+
+// parseLine turns an input line into an action. Empty lines are skipped.
+func parseLine(line string) (client.UserAction, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return client.UserAction{}, false, nil
+	}
+	if !strings.HasPrefix(line, "{") {
+		return client.UserAction{Type: "SEND_MESSAGE", Payload: line}, true, nil
+	}
+	var action client.UserAction
+	if err := json.Unmarshal([]byte(line), &action); err != nil {
+		return client.UserAction{}, false, fmt.Errorf("invalid action: %v", err)
+	}
+	if action.Type == "" {
+		return client.UserAction{}, false, fmt.Errorf("invalid action: missing type")
+	}
+	return action, true, nil
+}
+
+// writeEvents prints every event until the client reports SHUTDOWN.
+func (h *headless) writeEvents() {
+	for event := range h.eventsChan {
+		h.write(event)
+		if event.Type == "SHUTDOWN" {
+			return
+		}
+	}
+}
+
+func (h *headless) write(event client.DisplayEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Could not encode %s event: %v", event.Type, err)
+		return
+	}
+	h.outMu.Lock()
+	defer h.outMu.Unlock()
+	h.out.Write(append(data, '\n'))
+}
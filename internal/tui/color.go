@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// colorScreen wraps the terminal screen so colors can be dropped at draw
+// time. With noColor set, every cell is drawn in the terminal's default
+// colors; cells with a non-theme background are reversed so selections and
+// input fields stay visible.
+type colorScreen struct {
+	tcell.Screen
+	noColor bool
+	bg      tcell.Color
+	ready   bool
+}
+
+// Init initializes the screen once; tview calls it again in SetScreen.
+func (s *colorScreen) Init() error {
+	if s.ready {
+		return nil
+	}
+	if err := s.Screen.Init(); err != nil {
+		return err
+	}
+	s.ready = true
+	return nil
+}
+
+func (s *colorScreen) SetContent(x, y int, primary rune, combining []rune, style tcell.Style) {
+	if s.noColor {
+		fg, bg, attrs := style.Decompose()
+		style = tcell.StyleDefault.Attributes(attrs)
+		if bg != tcell.ColorDefault && bg != s.bg && bg != fg {
+			style = style.Reverse(true)
+		}
+	}
+	s.Screen.SetContent(x, y, primary, combining, style)
+}
+
+// checkColors records how many colors the terminal supports on the first
+// draw and re-applies the theme when it lacks truecolor.
+func (t *tui) checkColors(screen tcell.Screen) {
+	if t.colorCount != 0 {
+		return
+	}
+	t.colorCount = max(screen.Colors(), 1)
+	if t.colorCount < 1<<24 {
+		go t.app.QueueUpdateDraw(func() { t.setTheme(t.themeName) })
+	}
+}
+
+// fitTheme maps the RGB colors of th to the nearest colors the terminal
+// can show. It returns th unchanged on truecolor terminals.
+func (t *tui) fitTheme(th *theme) *theme {
+	if t.colorCount == 0 || t.colorCount >= 1<<24 {
+		return th
+	}
+	palette := make([]tcell.Color, min(t.colorCount, 256))
+	for i := range palette {
+		palette[i] = tcell.PaletteColor(i)
+	}
+	fit := func(c tcell.Color) tcell.Color {
+		if !c.IsRGB() {
+			return c
+		}
+		return tcell.FindColor(c, palette)
+	}
+
+	out := *th
+	for _, c := range []*tcell.Color{
+		&out.backgroundColor, &out.textColor, &out.borderColor, &out.titleColor,
+		&out.inputBgColor, &out.inputTextColor, &out.logInfoColor, &out.logWarnColor,
+		&out.logErrorColor, &out.highlightColor, &out.ownMsgColor,
+	} {
+		*c = fit(*c)
+	}
+	out.nickPalette = make([]string, len(th.nickPalette))
+	for i, tag := range th.nickPalette {
+		out.nickPalette[i] = tag
+		if c := tcell.GetColor(strings.Trim(tag, "[]")); c.IsRGB() {
+			out.nickPalette[i] = fmt.Sprintf("[#%06x]", fit(c).Hex())
+		}
+	}
+	return &out
+}
+
+// NoColor draws the whole interface in the terminal's default colors.
+func (t *tui) NoColor() {
+	t.forceNoColor = true
+	t.screenWrap.noColor = true
+}
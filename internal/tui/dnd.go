@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+// setDND handles /dnd [on|off|duration]. Without an argument it toggles do
+// not disturb; a duration such as 30m turns it on until it expires.
+func (t *tui) setDND(arg string) {
+	arg = strings.ToLower(strings.TrimSpace(arg))
+	switch arg {
+	case "":
+		if t.dnd {
+			t.clearDND("Do not disturb disabled.")
+		} else {
+			t.enableDND(0)
+		}
+	case "on":
+		t.enableDND(0)
+	case "off":
+		t.clearDND("Do not disturb disabled.")
+	default:
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			t.handleLogMessage(client.DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Invalid DND duration: '%s'. Use e.g. 30m or 2h.", arg)})
+			return
+		}
+		t.enableDND(d)
+	}
+}
+
+// enableDND silences bells and mention markers, for d if it is positive.
+func (t *tui) enableDND(d time.Duration) {
+	t.dnd = true
+	t.dndGen++
+	t.dndUntil = time.Time{}
+	msg := "Do not disturb enabled."
+	if d > 0 {
+		t.dndUntil = time.Now().Add(d)
+		msg = fmt.Sprintf("Do not disturb enabled until %s.", t.dndUntil.Format("15:04"))
+		gen := t.dndGen
+		time.AfterFunc(d, func() {
+			t.app.QueueUpdateDraw(func() {
+				if t.dnd && t.dndGen == gen {
+					t.clearDND("Do not disturb expired.")
+				}
+			})
+		})
+	}
+	t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: msg})
+	t.updateStatusLine()
+}
+
+func (t *tui) clearDND(msg string) {
+	if !t.dnd {
+		return
+	}
+	t.dnd = false
+	t.dndGen++
+	t.dndUntil = time.Time{}
+	t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: msg})
+	t.updateStatusLine()
+}
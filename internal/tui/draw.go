@@ -2,12 +2,16 @@ package tui
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mmcloughlin/geohash"
 	"github.com/rivo/tview"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
 )
 
 // updateChatList refreshes the chat list view, indicating the active and selected chats.
@@ -38,6 +42,14 @@ func (t *tui) updateChatList() {
 			viewName = fmt.Sprintf("%s [PoW:%d]", view.Name, view.PoW)
 		}
 
+		unread := t.unread[view.Name]
+		for _, child := range view.Children {
+			unread += t.unread[child]
+		}
+		if unread > 0 && !isActive {
+			viewName = fmt.Sprintf("%s (%d)", viewName, unread)
+		}
+
 		t.chatList.AddItem(fmt.Sprintf(" %s %s", prefix, viewName), "", 0, nil)
 	}
 
@@ -52,7 +64,6 @@ func (t *tui) updateChatList() {
 
 // updateDetailsView refreshes the details panel, showing relays or group members.
 func (t *tui) updateDetailsView() {
-	t.detailsView.SetTitle(titleInfo)
 	t.detailsView.Clear()
 
 	if t.chatList.GetItemCount() == 0 || len(t.views) == 0 {
@@ -73,11 +84,12 @@ func (t *tui) updateDetailsView() {
 		fmt.Fprint(t.detailsView, builder.String())
 	} else {
 		var builder strings.Builder
+		if geohash.Validate(selectedView.Name) == nil {
+			builder.WriteString(geohashArea(selectedView.Name, t.theme.logWarnColor))
+		}
 		builder.WriteString(fmt.Sprintf("[%s]Connected Relays:[-]\n", t.theme.logWarnColor))
 
-		sort.SliceStable(t.relays, func(i, j int) bool {
-			return t.relays[i].URL < t.relays[j].URL
-		})
+		t.sortRelays()
 
 		if len(t.relays) == 0 {
 			builder.WriteString(fmt.Sprintf(" [%s]Not connected...[-]\n", t.theme.logInfoColor))
@@ -97,13 +109,81 @@ func (t *tui) updateDetailsView() {
 					symbol = "●"
 				}
 				host := strings.TrimPrefix(strings.TrimPrefix(r.URL, "wss://"), "ws://")
-				builder.WriteString(fmt.Sprintf(" [%s]%s[-] %s\n", statusColor, symbol, host))
+				eose := ""
+				if r.Connected && r.ChatCount > 0 && !r.ReceivedEOSE {
+					eose = "…"
+				}
+				builder.WriteString(fmt.Sprintf(" [%s]%s[-] [%s]%s[-] %s [%s](%d%s)[-]\n",
+					statusColor, symbol, t.theme.logInfoColor, relayGlyphs[r.Type], host, t.theme.logInfoColor, r.ChatCount, eose))
 			}
+			builder.WriteString(fmt.Sprintf(" [%s](chats, … = awaiting stored events)\n a anchor, g geo, d discovered, - default[-]\n", t.theme.logInfoColor))
 		}
 		fmt.Fprint(t.detailsView, builder.String())
 	}
 }
 
+// relayGlyphs mark the source of each relay in the Info pane.
+var relayGlyphs = map[string]string{
+	client.RelayAnchor:     "a",
+	client.RelayGeo:        "g",
+	client.RelayDiscovered: "d",
+	client.RelayDefault:    "-",
+}
+
+// relayTypeOrder ranks relay sources for the "type" sort.
+var relayTypeOrder = map[string]int{
+	client.RelayAnchor:     0,
+	client.RelayGeo:        1,
+	client.RelayDiscovered: 2,
+	client.RelayDefault:    3,
+}
+
+// sortRelays orders the relay list by URL, by latency with disconnected
+// relays last, or grouped by source, as set by relay_sort.
+func (t *tui) sortRelays() {
+	sort.SliceStable(t.relays, func(i, j int) bool {
+		a, b := t.relays[i], t.relays[j]
+		switch t.relaySort {
+		case client.RelaySortLatency:
+			if a.Connected != b.Connected {
+				return a.Connected
+			}
+			if a.Latency != b.Latency {
+				return a.Latency < b.Latency
+			}
+		case client.RelaySortType:
+			if ra, rb := relayTypeOrder[a.Type], relayTypeOrder[b.Type]; ra != rb {
+				return ra < rb
+			}
+		}
+		return a.URL < b.URL
+	})
+}
+
+// geohashArea describes the center and cell size of a geohash for the Info pane.
+func geohashArea(hash string, headerColor tcell.Color) string {
+	const kmPerDegree = 111.32
+	lat, lng := geohash.DecodeCenter(hash)
+	box := geohash.BoundingBox(hash)
+	height := (box.MaxLat - box.MinLat) * kmPerDegree
+	width := (box.MaxLng - box.MinLng) * kmPerDegree * math.Cos(lat*math.Pi/180)
+
+	return fmt.Sprintf("[%s]Area:[-]\n Center: %.4f, %.4f\n Cell: ~%s × %s\n\n",
+		headerColor, lat, lng, formatKm(width), formatKm(height))
+}
+
+// formatKm formats a distance with a unit suited to its magnitude.
+func formatKm(km float64) string {
+	switch {
+	case km < 1:
+		return fmt.Sprintf("%.0f m", km*1000)
+	case km < 10:
+		return fmt.Sprintf("%.1f km", km)
+	default:
+		return fmt.Sprintf("%.0f km", km)
+	}
+}
+
 // updateInputLabel sets the prompt label for the input field, including the user's nick.
 func (t *tui) updateInputLabel() {
 	if t.nick != "" {
@@ -113,9 +193,46 @@ func (t *tui) updateInputLabel() {
 	}
 }
 
+// paneTitle builds a pane title from its name and focus key binding.
+func (t *tui) paneTitle(name, action string) string {
+	if t.narrowMode {
+		return tview.Escape(t.keyDesc(action))
+	}
+	return fmt.Sprintf("%s (%s)", name, tview.Escape(t.keyDesc(action)))
+}
+
+// updateTitles refreshes all pane titles.
+func (t *tui) updateTitles() {
+	t.logs.SetTitle(t.paneTitle(titleLogs, "focus_logs"))
+	t.chatList.SetTitle(t.paneTitle(titleChats, "focus_chats"))
+	t.detailsView.SetTitle(t.paneTitle(titleInfo, "focus_info"))
+	t.input.SetTitle(t.paneTitle(titleInput, "focus_input"))
+	t.compose.SetTitle(t.paneTitle(titleCompose, "focus_input"))
+	t.updateOutputTitle()
+}
+
+// updateOutputTitle sets the Messages title, marking it when a mention is pending.
+func (t *tui) updateOutputTitle() {
+	title := t.paneTitle(titleMessages, "focus_output")
+	if t.mentionPending {
+		title = fmt.Sprintf("%s [%s]@[-]", title, t.theme.logWarnColor)
+	}
+	if t.paused {
+		title = fmt.Sprintf("%s [%s]paused, %d new below (p: resume)[-]", title, t.theme.logWarnColor, len(t.pausedMsgs))
+	} else if t.unseenBelow > 0 {
+		title = fmt.Sprintf("%s [%s]%d new below (End: jump)[-]", title, t.theme.logWarnColor, t.unseenBelow)
+	}
+	t.output.SetTitle(title)
+}
+
 // updateFocusBorders changes widget border colors to highlight the focused element.
 func (t *tui) updateFocusBorders() {
 	currentFocus := t.app.GetFocus()
+
+	if currentFocus == t.output && t.mentionPending {
+		t.mentionPending = false
+		t.updateOutputTitle()
+	}
 	unfocusedColor := tview.Styles.BorderColor
 	focusedColor := tview.Styles.TitleColor
 
@@ -125,6 +242,7 @@ func (t *tui) updateFocusBorders() {
 		t.detailsView: false,
 		t.output:      false,
 		t.input:       false,
+		t.compose:     false,
 	}
 
 	if _, ok := components[currentFocus]; ok {
@@ -136,33 +254,89 @@ func (t *tui) updateFocusBorders() {
 	t.detailsView.SetBorderColor(map[bool]tcell.Color{true: focusedColor, false: unfocusedColor}[components[t.detailsView]])
 	t.output.SetBorderColor(map[bool]tcell.Color{true: focusedColor, false: unfocusedColor}[components[t.output]])
 	t.input.SetBorderColor(map[bool]tcell.Color{true: focusedColor, false: unfocusedColor}[components[t.input]])
+	t.compose.SetBorderColor(map[bool]tcell.Color{true: focusedColor, false: unfocusedColor}[components[t.compose]])
+}
+
+// powSpinner holds the frames of the status line's mining indicator.
+var powSpinner = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// updateStatusLine summarizes the active view, nick, PoW and relay connections.
+func (t *tui) updateStatusLine() {
+	name := "none"
+	if t.activeViewIndex >= 0 && t.activeViewIndex < len(t.views) {
+		name = t.views[t.activeViewIndex].Name
+	}
+	connected := 0
+	for _, r := range t.relays {
+		if r.Connected {
+			connected++
+		}
+	}
+	pow := "off"
+	if t.pow > 0 {
+		pow = fmt.Sprint(t.pow)
+	}
+	dnd := ""
+	if t.dnd {
+		dnd = fmt.Sprintf(" | [%s]DND[-]", t.theme.logWarnColor)
+		if !t.dndUntil.IsZero() {
+			dnd += " until " + t.dndUntil.Format("15:04")
+		}
+	}
+	if t.powProgress != "" {
+		frame := powSpinner[t.powSpin%len(powSpinner)]
+		pow += fmt.Sprintf(" [%s]%c mining[-] (%s, Esc: cancel)", t.theme.logWarnColor, frame, tview.Escape(t.powProgress))
+	}
+	t.statusLine.SetText(fmt.Sprintf(
+		" [%[1]s]Chat:[-] %[2]s | [%[1]s]Nick:[-] %[3]s | [%[1]s]PoW:[-] %[4]s | %[5]d/%[6]d relays connected%[7]s",
+		t.theme.titleColor, tview.Escape(name), tview.Escape(t.nick), pow, connected, len(t.relays), dnd,
+	))
 }
 
 // updateHints displays context-sensitive hints for the user.
 func (t *tui) updateHints() {
 	var hintText string
 	highlight := t.theme.titleColor
-	baseHints := fmt.Sprintf("[%[1]s]Alt+...[-]: Focus | [%[1]s]Ctrl+C[-]: Quit", highlight)
+	baseHints := fmt.Sprintf("[%[1]s]%[2]s[-]: Focus | [%[1]s]Ctrl+C[-]: Quit", highlight, t.focusHint())
+	maximize := tview.Escape(t.keyDesc("maximize"))
+	history := tview.Escape(t.keyDesc("history_prev") + "/" + t.keyDesc("history_next"))
+	compose := tview.Escape(t.keyDesc("compose"))
 
 	if t.logsMaximized {
-		hintText = fmt.Sprintf("[%[1]s]`[-]: Restore | [%[1]s]↑/↓[-]: Scroll | [%[1]s]Ctrl+C[-]: Quit", highlight)
+		hintText = fmt.Sprintf("[%[1]s]%[2]s[-]: Restore | [%[1]s]↑/↓[-]: Scroll | [%[1]s]Ctrl+C[-]: Quit", highlight, maximize)
 	} else if t.outputMaximized {
-		hintText = fmt.Sprintf("[%[1]s]`[-]: Restore | [%[1]s]↑/↓[-]: Scroll | [%[1]s]Ctrl+C[-]: Quit", highlight)
+		hintText = fmt.Sprintf("[%[1]s]%[2]s[-]: Restore | [%[1]s]↑/↓[-]: Scroll | [%[1]s]End[-]: Bottom | [%[1]s]o[-]: Open URL | [%[1]s]y/Y[-]: Copy Msg/Pubkey | [%[1]s]w[-]: Whois | [%[1]s]e[-]: Expand | [%[1]s]p[-]: Pause | [%[1]s]/[-]: Search | [%[1]s]n/N[-]: Older/Newer Match | [%[1]s]Ctrl+C[-]: Quit", highlight, maximize)
 	} else {
 		switch t.app.GetFocus() {
 		case t.input:
-			hintText = fmt.Sprintf("[%[1]s]Enter[-]: Send | [%[1]s]Ctrl+P/N[-]: History | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %s", highlight, baseHints)
+			hintText = fmt.Sprintf("[%[1]s]Enter[-]: Send | [%[1]s]↑/↓[-]: Input History | [%[1]s]%[2]s[-]: Recipients | [%[1]s]%[3]s[-]: Multi-line | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %[4]s", highlight, history, compose, baseHints)
+		case t.compose:
+			hintText = fmt.Sprintf("[%[1]s]%[2]s[-]: Send | [%[1]s]Enter[-]: New Line | [%[1]s]%[3]s[-]: Single-line | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %[4]s", highlight, tview.Escape(t.keyDesc("compose_send")), compose, baseHints)
 		case t.output:
-			hintText = fmt.Sprintf("[%[1]s]`[-]: Maximize | [%[1]s]↑/↓[-]: Scroll | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %s", highlight, baseHints)
+			hintText = fmt.Sprintf("[%[1]s]%[2]s[-]: Maximize | [%[1]s]↑/↓[-]: Scroll | [%[1]s]End[-]: Bottom | [%[1]s]o[-]: Open URL | [%[1]s]y/Y[-]: Copy Msg/Pubkey | [%[1]s]w[-]: Whois | [%[1]s]e[-]: Expand | [%[1]s]p[-]: Pause | [%[1]s]/[-]: Search | [%[1]s]n/N[-]: Older/Newer Match | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %[3]s", highlight, maximize, baseHints)
 		case t.detailsView:
 			hintText = fmt.Sprintf("[%[1]s]↑/↓[-]: Scroll | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %s", highlight, baseHints)
 		case t.chatList:
 			hintText = fmt.Sprintf("[%[1]s]Space[-]: Select | [%[1]s]Enter[-]: Activate | [%[1]s]Del[-]: Delete | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %s", highlight, baseHints)
 		case t.logs:
-			hintText = fmt.Sprintf("[%[1]s]`[-]: Maximize | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %s", highlight, baseHints)
+			hintText = fmt.Sprintf("[%[1]s]%[2]s[-]: Maximize | [%[1]s]Tab/Shift+Tab[-]: Cycle Focus | %[3]s", highlight, maximize, baseHints)
 		default:
 			hintText = baseHints
 		}
 	}
 	t.hints.SetText(hintText)
 }
+
+// focusHint summarizes the focus bindings, e.g. "Alt+..." when they share a modifier.
+func (t *tui) focusHint() string {
+	prefix := ""
+	for i, action := range []string{"focus_chats", "focus_output", "focus_input", "focus_logs", "focus_info"} {
+		desc := t.keyDesc(action)
+		idx := strings.LastIndex(desc, "+")
+		if idx <= 0 || (i > 0 && desc[:idx+1] != prefix) {
+			return "See titles"
+		}
+		prefix = desc[:idx+1]
+	}
+	return tview.Escape(prefix + "...")
+}
@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+// exportedMsg is the JSON form of a message written by /export-chat.
+type exportedMsg struct {
+	Timestamp string `json:"timestamp"`
+	Chat      string `json:"chat"`
+	Nick      string `json:"nick"`
+	PubKey    string `json:"pubkey"`
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	Own       bool   `json:"own,omitempty"`
+}
+
+// exportChat writes the messages displayed for the active view to path, as
+// JSON if the file name ends in .json and as plain text otherwise.
+func (t *tui) exportChat(path string) {
+	if path == "" {
+		t.handleLogMessage(client.DisplayEvent{Type: "ERROR", Content: "Usage: /export-chat <path>[.json]"})
+		return
+	}
+	view := t.findView("")
+	if view == nil {
+		t.handleLogMessage(client.DisplayEvent{Type: "ERROR", Content: "No active chat/group to export."})
+		return
+	}
+
+	var msgs []exportedMsg
+	for _, m := range t.renderedMsgs {
+		ev := m.msg.event
+		if ev.Chat != view.Name && !slices.Contains(view.Children, ev.Chat) {
+			continue
+		}
+		content := ev.Content
+		if ev.RawContent != "" {
+			content = ev.RawContent
+		}
+		msgs = append(msgs, exportedMsg{
+			Timestamp: ev.Timestamp,
+			Chat:      ev.Chat,
+			Nick:      ev.Nick + "#" + ev.ShortPubKey,
+			PubKey:    ev.FullPubKey,
+			ID:        ev.ID,
+			Content:   content,
+			Own:       ev.IsOwnMessage,
+		})
+	}
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, _ = json.MarshalIndent(msgs, "", "  ")
+	} else {
+		var b strings.Builder
+		for _, m := range msgs {
+			fmt.Fprintf(&b, "[%s] %s %s: %s\n", m.Timestamp, m.Chat, m.Nick, m.Content)
+		}
+		data = []byte(b.String())
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.handleLogMessage(client.DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not export chat: %v", err)})
+		return
+	}
+	t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Exported %d message(s) from %s to %s.", len(msgs), view.Name, path)})
+}
@@ -0,0 +1,90 @@
+package tui
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+const (
+	inputHistoryFileName = "input_history.json"
+	inputHistorySize     = 100
+)
+
+// loadInputHistory restores the submitted lines saved by a previous run.
+func (t *tui) loadInputHistory() {
+	dir, err := client.ConfigDir()
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(dir, inputHistoryFileName))
+	if err != nil {
+		return
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		log.Printf("Could not decode %s: %v", inputHistoryFileName, err)
+		return
+	}
+	t.inputHistory = lines[max(0, len(lines)-inputHistorySize):]
+}
+
+// saveInputHistory writes the input history next to config.json.
+func (t *tui) saveInputHistory() {
+	data, _ := json.MarshalIndent(t.inputHistory, "", "  ")
+	if err := client.WriteConfigFile(inputHistoryFileName, data); err != nil {
+		log.Printf("Could not save input history: %v", err)
+	}
+}
+
+// rememberInput appends a submitted line to the history. Repeats of the last
+// line are skipped, and /import is never recorded since it carries a private key.
+func (t *tui) rememberInput(text string) {
+	t.histIdx = -1
+	if cmd, _, _ := strings.Cut(text, " "); cmd == "/import" {
+		return
+	}
+	if n := len(t.inputHistory); n > 0 && t.inputHistory[n-1] == text {
+		return
+	}
+	t.inputHistory = append(t.inputHistory, text)
+	if len(t.inputHistory) > inputHistorySize {
+		t.inputHistory = t.inputHistory[len(t.inputHistory)-inputHistorySize:]
+	}
+	t.saveInputHistory()
+}
+
+// walkInputHistory moves through the history with Up (older) and Down (newer).
+// It only acts while the field is empty or still shows a recalled line, so
+// editing a line or navigating the autocomplete list is left alone.
+func (t *tui) walkInputHistory(older bool) bool {
+	if len(t.inputHistory) == 0 {
+		return false
+	}
+	text := t.input.GetText()
+	navigating := t.histIdx >= 0 && t.histIdx < len(t.inputHistory) && text == t.inputHistory[t.histIdx]
+	if text != "" && !navigating {
+		return false
+	}
+
+	switch {
+	case older && !navigating:
+		t.histIdx = len(t.inputHistory) - 1
+	case older:
+		t.histIdx = max(0, t.histIdx-1)
+	case !navigating:
+		return false
+	case t.histIdx == len(t.inputHistory)-1:
+		t.histIdx = -1
+		t.input.SetText("")
+		return true
+	default:
+		t.histIdx++
+	}
+	t.input.SetText(t.inputHistory[t.histIdx])
+	return true
+}
@@ -1,6 +1,8 @@
 package tui
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
@@ -17,45 +19,20 @@ func (t *tui) setupHandlers() {
 			return
 		}
 		defer t.input.SetText("")
-
-		text := strings.TrimSpace(t.input.GetText())
-		if text == "" {
-			return
-		}
-
-		if strings.HasPrefix(text, "/") {
-			t.handleCommand(text)
-		} else {
-			t.actionsChan <- client.UserAction{Type: "SEND_MESSAGE", Payload: text}
-		}
-
-		// Logic to add the recipient to the recent recipients list.
-		if !strings.HasPrefix(text, "/") {
-			nick, complete := extractNickPrefix(text)
-			if complete {
-				nick = strings.TrimPrefix(nick, "@")
-				for i, n := range t.recentRecipients {
-					if n == nick {
-						t.recentRecipients = append(t.recentRecipients[:i], t.recentRecipients[i+1:]...)
-						break
-					}
-				}
-				t.recentRecipients = append([]string{nick}, t.recentRecipients...)
-				if len(t.recentRecipients) > 20 {
-					t.recentRecipients = t.recentRecipients[:20]
-				}
-			}
-		}
+		t.submitInput(t.input.GetText())
 	})
 
-	// Configure recipient history navigation with Ctrl+P/N.
+	t.compose.SetInputCapture(t.composeKey)
+
+	// Configure recipient history navigation (Ctrl+P/N by default).
 	t.input.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-		if ev.Key() == tcell.KeyCtrlP || ev.Key() == tcell.KeyCtrlN {
+		prev, next := t.keys["history_prev"].matches(ev), t.keys["history_next"].matches(ev)
+		if prev || next {
 			if len(t.recentRecipients) == 0 {
 				return ev
 			}
 
-			if ev.Key() == tcell.KeyCtrlP {
+			if prev {
 				t.rrIdx = (t.rrIdx + 1) % len(t.recentRecipients)
 			} else {
 				if t.rrIdx <= 0 {
@@ -69,18 +46,38 @@ func (t *tui) setupHandlers() {
 			return nil
 		}
 
+		if key := ev.Key(); key == tcell.KeyUp || key == tcell.KeyDown {
+			if t.walkInputHistory(key == tcell.KeyUp) {
+				return nil
+			}
+		}
+
 		t.rrIdx = -1
 		return ev
 	})
 
+	t.setupSearch()
+
 	// Set up global key handlers for focus, exiting, etc.
 	t.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if (t.modalOpen || t.app.GetFocus() == t.searchInput) && event.Key() != tcell.KeyCtrlC {
+			return event
+		}
+
+		if event.Key() == tcell.KeyEscape && t.powProgress != "" {
+			t.actionsChan <- client.UserAction{Type: "CANCEL_POW"}
+			return nil
+		}
+
 		if t.logsMaximized || t.outputMaximized {
 			return t.handleMaximizedViewKeys(event)
 		}
 
 		switch event.Key() {
 		case tcell.KeyTab:
+			if t.app.GetFocus() == t.input && t.completeCommand() {
+				return nil
+			}
 			t.cycleFocus(true)
 			return nil
 		case tcell.KeyBacktab:
@@ -88,44 +85,58 @@ func (t *tui) setupHandlers() {
 			return nil
 		}
 
-		if event.Modifiers() == tcell.ModAlt {
-			switch event.Rune() {
-			case 'c':
-				t.app.SetFocus(t.chatList)
-			case 'o':
-				t.app.SetFocus(t.output)
-			case 'i':
-				t.app.SetFocus(t.input)
-			case 'l':
-				t.app.SetFocus(t.logs)
-			case 'n':
-				t.app.SetFocus(t.detailsView)
-			}
-			t.updateFocusBorders()
-			t.updateHints()
+		if t.keys["compose"].matches(event) {
+			t.toggleCompose()
 			return nil
 		}
 
+		focusTargets := []struct {
+			action string
+			target tview.Primitive
+		}{
+			{"focus_chats", t.chatList},
+			{"focus_output", t.output},
+			{"focus_input", t.activeInput()},
+			{"focus_logs", t.logs},
+			{"focus_info", t.detailsView},
+		}
+		for _, ft := range focusTargets {
+			if t.keys[ft.action].matches(event) {
+				t.app.SetFocus(ft.target)
+				t.updateFocusBorders()
+				t.updateHints()
+				return nil
+			}
+		}
+
 		currentFocus := t.app.GetFocus()
 
 		if currentFocus == t.chatList {
 			return t.handleChatListKeys(event)
 		}
 
-		if currentFocus == t.logs && event.Key() == tcell.KeyRune && event.Rune() == '`' {
+		maximize := t.keys["maximize"].matches(event)
+
+		if currentFocus == t.logs && maximize {
 			t.logsMaximized = true
 			t.app.SetRoot(t.maximizedLogsFlex, true).SetFocus(t.logs)
 			t.updateHints()
 			return nil
 		}
 
-		if currentFocus == t.output && event.Key() == tcell.KeyRune && event.Rune() == '`' {
+		if currentFocus == t.output && maximize {
 			t.outputMaximized = true
 			t.app.SetRoot(t.maximizedOutputFlex, true).SetFocus(t.output)
 			t.updateHints()
 			return nil
 		}
 
+		if currentFocus == t.output {
+			if t.handleOutputKeys(event) == nil {
+				return nil
+			}
+		}
+
 		if event.Key() == tcell.KeyCtrlC {
 			t.actionsChan <- client.UserAction{Type: "QUIT"}
 			return nil
@@ -137,32 +148,74 @@ func (t *tui) setupHandlers() {
 	t.chatList.SetChangedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
 		t.updateDetailsView()
 	})
+
+	// Enter is handled in handleChatListKeys, so this only fires on mouse clicks.
+	t.chatList.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		if index < 0 || index >= len(t.views) {
+			return
+		}
+		t.actionsChan <- client.UserAction{Type: "ACTIVATE_VIEW", Payload: t.views[index].Name}
+	})
+
+	// Clicking a pane moves focus, so keep borders and hints in sync.
+	t.app.SetMouseCapture(func(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+		if action == tview.MouseLeftClick {
+			go t.app.QueueUpdateDraw(func() {
+				t.updateFocusBorders()
+				t.updateHints()
+			})
+		}
+		return event, action
+	})
 }
 
-// handleCommand parses and dispatches actions for slash-commands.
-func (t *tui) handleCommand(text string) {
-	parts := strings.SplitN(text, " ", 2)
-	command := parts[0]
-	payload := ""
-	if len(parts) > 1 {
-		payload = parts[1]
+// composeKey handles keys of the compose field: the compose_send key submits
+// the text and every other key, Enter included, goes on to the TextArea.
+func (t *tui) composeKey(ev *tcell.EventKey) *tcell.EventKey {
+	if !t.keys["compose_send"].matches(ev) {
+		return ev
 	}
-	switch command {
-	case "/quit", "/q":
-		t.actionsChan <- client.UserAction{Type: "QUIT"}
-	case "/join", "/j":
+	text := t.compose.GetText()
+	if n := graphemeLen(strings.TrimSpace(text)); n > t.maxMsgLen {
+		t.handleLogMessage(client.DisplayEvent{
+			Type:    "ERROR",
+			Content: fmt.Sprintf("Message is too long (%d/%d characters).", n, t.maxMsgLen),
+		})
+		return nil
+	}
+	t.compose.SetText("", false)
+	t.submitInput(text)
+	return nil
+}
+
+// command is a slash-command: its name first, then its aliases, and what it does.
+type command struct {
+	names []string
+	run   func(t *tui, payload string)
+}
+
+// commands is the single list of slash-commands. handleCommand dispatches
+// through it and completion offers every name and alias in it.
+var commands = []command{
+	{[]string{"/quit", "/q"}, sendAction("QUIT")},
+	{[]string{"/join", "/j"}, func(t *tui, payload string) {
 		if payload != "" {
 			t.actionsChan <- client.UserAction{Type: "JOIN_CHATS", Payload: payload}
 		}
-	case "/pow", "/p":
-		if payload != "" {
-			t.actionsChan <- client.UserAction{Type: "SET_POW", Payload: payload}
-		} else {
-			t.actionsChan <- client.UserAction{Type: "SET_POW", Payload: "0"}
+	}},
+	{[]string{"/near"}, sendPayload("JOIN_NEAR")},
+	{[]string{"/zoom"}, sendPayload("ZOOM_CHAT")},
+	{[]string{"/pow", "/p"}, func(t *tui, payload string) {
+		if payload == "" {
+			payload = "0"
 		}
-	case "/list", "/l":
-		t.actionsChan <- client.UserAction{Type: "LIST_CHATS"}
-	case "/set", "/s":
+		t.actionsChan <- client.UserAction{Type: "SET_POW", Payload: payload}
+	}},
+	{[]string{"/broadcast"}, sendPayload("SET_BROADCAST")},
+	{[]string{"/notify"}, sendPayload("SET_NOTIFY")},
+	{[]string{"/list", "/l"}, sendAction("LIST_CHATS")},
+	{[]string{"/history"}, sendPayload("LOAD_HISTORY")},
+	{[]string{"/set", "/s"}, func(t *tui, payload string) {
 		args := strings.Fields(payload)
 		switch len(args) {
 		case 0:
@@ -173,48 +226,323 @@ func (t *tui) handleCommand(text string) {
 			groupMembers := strings.Join(args, ",")
 			t.actionsChan <- client.UserAction{Type: "CREATE_GROUP", Payload: groupMembers}
 		}
-	case "/nick", "/n":
-		t.actionsChan <- client.UserAction{Type: "SET_NICK", Payload: payload}
-	case "/del", "/d":
-		t.actionsChan <- client.UserAction{Type: "DELETE_VIEW", Payload: payload}
-	case "/block", "/b":
-		if payload == "" {
-			t.actionsChan <- client.UserAction{Type: "LIST_BLOCKED"}
-		} else {
-			t.actionsChan <- client.UserAction{Type: "BLOCK_USER", Payload: payload}
+	}},
+	{[]string{"/nick", "/n"}, sendPayload("SET_NICK")},
+	{[]string{"/import"}, sendPayload("IMPORT_KEY")},
+	{[]string{"/export"}, sendPayload("EXPORT_KEY")},
+	{[]string{"/del", "/d"}, func(t *tui, payload string) {
+		send := func() {
+			t.actionsChan <- client.UserAction{Type: "DELETE_VIEW", Payload: payload}
 		}
-	case "/unblock", "/ub":
-		if payload == "" {
-			t.actionsChan <- client.UserAction{Type: "LIST_BLOCKED"}
+		if v := t.findView(strings.TrimSpace(payload)); t.confirmDeletes && v != nil && v.IsGroup {
+			t.confirm(fmt.Sprintf("Delete group %s?", v.Name), send)
 		} else {
-			t.actionsChan <- client.UserAction{Type: "UNBLOCK_USER", Payload: payload}
+			send()
 		}
-	case "/filter", "/f":
-		t.actionsChan <- client.UserAction{Type: "HANDLE_FILTER", Payload: payload}
-	case "/unfilter", "/uf":
+	}},
+	{[]string{"/undo"}, sendAction("UNDO_REMOVE")},
+	{[]string{"/block", "/b"}, listOrSend("LIST_BLOCKED", "BLOCK_USER")},
+	{[]string{"/unblock", "/ub"}, listOrSend("LIST_BLOCKED", "UNBLOCK_USER")},
+	{[]string{"/whois", "/w"}, sendPayload("WHOIS")},
+	{[]string{"/filter", "/f"}, sendPayload("HANDLE_FILTER")},
+	{[]string{"/unfilter", "/uf"}, listOrSend("CLEAR_FILTERS", "REMOVE_FILTER")},
+	{[]string{"/mute", "/m"}, sendPayload("HANDLE_MUTE")},
+	{[]string{"/unmute", "/um"}, listOrSend("CLEAR_MUTES", "REMOVE_MUTE")},
+	{[]string{"/highlight", "/hl"}, sendPayload("HANDLE_HIGHLIGHT")},
+	{[]string{"/unhighlight", "/uhl"}, listOrSend("CLEAR_HIGHLIGHTS", "REMOVE_HIGHLIGHT")},
+	{[]string{"/reply", "/re"}, func(t *tui, payload string) {
+		t.actionsChan <- client.UserAction{Type: "SEND_REPLY", Payload: payload}
+		delete(t.drafts, t.activeViewName())
+	}},
+	{[]string{"/dm"}, sendPayload("SET_DMS")},
+	{[]string{"/who"}, sendPayload("LIST_PRESENT")},
+	{[]string{"/stats"}, sendAction("GET_STATS")},
+	{[]string{"/debug"}, sendPayload("SET_DEBUG")},
+	{[]string{"/export-chat"}, func(t *tui, payload string) { t.exportChat(strings.TrimSpace(payload)) }},
+	{[]string{"/log"}, sendPayload("SET_CHAT_LOG")},
+	{[]string{"/timeformat"}, sendPayload("SET_TIME_FORMAT")},
+	{[]string{"/layout"}, sendPayload("SET_LAYOUT")},
+	{[]string{"/relay", "/r"}, func(t *tui, payload string) {
+		args := strings.Fields(payload)
+		sub := ""
+		if len(args) > 0 {
+			sub = args[0]
+		}
+		switch sub {
+		case "info":
+			t.actionsChan <- client.UserAction{Type: "RELAY_INFO", Payload: strings.Join(args[1:], " ")}
+		case "drop":
+			t.actionsChan <- client.UserAction{Type: "DROP_RELAY", Payload: strings.Join(args[1:], " ")}
+		case "reconnect":
+			t.actionsChan <- client.UserAction{Type: "RECONNECT_RELAY", Payload: strings.Join(args[1:], " ")}
+		case "publish":
+			t.actionsChan <- client.UserAction{Type: "PUBLISH_RELAY_LIST"}
+		default:
+			t.actionsChan <- client.UserAction{Type: "MANAGE_ANCHORS", Payload: payload}
+		}
+	}},
+	{[]string{"/georelays"}, sendPayload("SET_GEO_RELAY_COUNT")},
+	{[]string{"/discovery"}, sendPayload("SET_DISCOVERY")},
+	{[]string{"/theme"}, func(t *tui, payload string) { t.handleThemeCommand(strings.TrimSpace(payload)) }},
+	{[]string{"/dnd"}, (*tui).setDND},
+	{[]string{"/version"}, func(t *tui, _ string) {
+		t.handleInfoMessage(client.DisplayEvent{Type: "INFO", Content: t.version})
+	}},
+	{[]string{"/help", "/h"}, sendAction("GET_HELP")},
+}
+
+// commandNames lists every slash-command and alias for completion.
+var commandNames = func() []string {
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.names...)
+	}
+	return names
+}()
+
+// sendAction returns a command that sends an action of type typ without payload.
+func sendAction(typ string) func(*tui, string) {
+	return func(t *tui, _ string) {
+		t.actionsChan <- client.UserAction{Type: typ}
+	}
+}
+
+// sendPayload returns a command that sends an action of type typ with the
+// command's arguments as payload.
+func sendPayload(typ string) func(*tui, string) {
+	return func(t *tui, payload string) {
+		t.actionsChan <- client.UserAction{Type: typ, Payload: payload}
+	}
+}
+
+// listOrSend returns a command that sends list without arguments, and typ
+// with the arguments otherwise.
+func listOrSend(list, typ string) func(*tui, string) {
+	return func(t *tui, payload string) {
 		if payload == "" {
-			t.actionsChan <- client.UserAction{Type: "CLEAR_FILTERS"}
+			t.actionsChan <- client.UserAction{Type: list}
 		} else {
-			t.actionsChan <- client.UserAction{Type: "REMOVE_FILTER", Payload: payload}
+			t.actionsChan <- client.UserAction{Type: typ, Payload: payload}
 		}
-	case "/mute", "/m":
-		t.actionsChan <- client.UserAction{Type: "HANDLE_MUTE", Payload: payload}
-	case "/unmute", "/um":
-		if payload == "" {
-			t.actionsChan <- client.UserAction{Type: "CLEAR_MUTES"}
+	}
+}
+
+// commandMatches returns the commands starting with prefix, or nil if the
+// text is not a bare command prefix.
+func commandMatches(prefix string) []string {
+	if !strings.HasPrefix(prefix, "/") || strings.Contains(prefix, " ") {
+		return nil
+	}
+	var out []string
+	for _, name := range commandNames {
+		if strings.HasPrefix(name, prefix) {
+			out = append(out, name)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// completeCommand extends a partial command in the input field to the longest
+// unambiguous prefix, adding a space once it is unique. It reports whether the
+// text was a command prefix with at least one match.
+func (t *tui) completeCommand() bool {
+	text := t.input.GetText()
+	matches := commandMatches(text)
+	if len(matches) == 0 {
+		return false
+	}
+	if len(matches) == 1 {
+		t.input.SetText(matches[0] + " ")
+		return true
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(text) {
+		t.input.SetText(common)
+	}
+	return true
+}
+
+// handleCommand parses and dispatches actions for slash-commands.
+func (t *tui) handleCommand(text string) {
+	parts := strings.SplitN(text, " ", 2)
+	command := parts[0]
+	payload := ""
+	if len(parts) > 1 {
+		payload = parts[1]
+	}
+	for _, cmd := range commands {
+		if slices.Contains(cmd.names, command) {
+			cmd.run(t, payload)
+			return
+		}
+	}
+}
+
+// handleThemeCommand lists the available themes or switches to one and persists the choice.
+func (t *tui) handleThemeCommand(name string) {
+	if name == "" {
+		t.handleLogMessage(client.DisplayEvent{
+			Type:    "STATUS",
+			Content: fmt.Sprintf("Current theme: %s. Available: %s", t.themeName, strings.Join(themeNames(), ", ")),
+		})
+		return
+	}
+	if name == "reload" {
+		if _, err := loadCustomTheme(); err != nil {
+			t.handleLogMessage(client.DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not load custom theme: %v", err)})
+			return
+		}
+		name = customThemeName
+	}
+	if !t.setTheme(name) {
+		t.handleLogMessage(client.DisplayEvent{
+			Type:    "ERROR",
+			Content: fmt.Sprintf("Unknown theme '%s'. Available: %s", name, strings.Join(themeNames(), ", ")),
+		})
+		return
+	}
+	t.actionsChan <- client.UserAction{Type: "SET_THEME", Payload: name}
+	t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "Theme set to " + name})
+}
+
+// submitInput runs a command or sends a message typed in the input or compose field.
+func (t *tui) submitInput(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	t.rememberInput(text)
+
+	if strings.HasPrefix(text, "/") {
+		t.handleCommand(text)
+		return
+	}
+	t.actionsChan <- client.UserAction{Type: "SEND_MESSAGE", Payload: text}
+	delete(t.drafts, t.activeViewName())
+
+	// Logic to add the recipient to the recent recipients list.
+	nick, complete := extractNickPrefix(text)
+	if complete {
+		nick = strings.TrimPrefix(nick, "@")
+		for i, n := range t.recentRecipients {
+			if n == nick {
+				t.recentRecipients = append(t.recentRecipients[:i], t.recentRecipients[i+1:]...)
+				break
+			}
+		}
+		t.recentRecipients = append([]string{nick}, t.recentRecipients...)
+		if len(t.recentRecipients) > 20 {
+			t.recentRecipients = t.recentRecipients[:20]
+		}
+	}
+}
+
+// swapDraft saves the unsent text of the view being left and restores the
+// draft of the view being entered.
+func (t *tui) swapDraft(from, to string) {
+	text := t.input.GetText()
+	if t.composeMode {
+		text = t.compose.GetText()
+	}
+	if from != "" {
+		if strings.TrimSpace(text) == "" {
+			delete(t.drafts, from)
 		} else {
-			t.actionsChan <- client.UserAction{Type: "REMOVE_MUTE", Payload: payload}
+			t.drafts[from] = text
 		}
-	case "/relay", "/r":
-		t.actionsChan <- client.UserAction{Type: "MANAGE_ANCHORS", Payload: payload}
-	case "/help", "/h":
-		t.actionsChan <- client.UserAction{Type: "GET_HELP"}
+	}
+
+	draft := t.drafts[to]
+	if t.composeMode {
+		t.compose.SetText(draft, true)
+	} else {
+		t.input.SetText(strings.ReplaceAll(draft, "\n", " "))
 	}
 }
 
+// activeInput returns the field messages are typed into: the compose area in
+// multi-line mode, the input field otherwise.
+func (t *tui) activeInput() tview.Primitive {
+	if t.composeMode {
+		return t.compose
+	}
+	return t.input
+}
+
+// toggleCompose swaps the single-line input field for the multi-line compose
+// area and back, carrying over the text typed so far.
+func (t *tui) toggleCompose() {
+	t.composeMode = !t.composeMode
+	t.bottomFlex.Clear()
+	if t.composeMode {
+		t.compose.SetText(t.input.GetText(), true)
+		t.input.SetText("")
+		t.bottomFlex.AddItem(t.compose, 0, 1, true)
+		t.mainFlex.ResizeItem(t.bottomFlex, composeHeight, 0)
+	} else {
+		t.input.SetText(strings.ReplaceAll(t.compose.GetText(), "\n", " "))
+		t.compose.SetText("", false)
+		t.bottomFlex.AddItem(t.input, 0, 1, true)
+		t.mainFlex.ResizeItem(t.bottomFlex, inputHeight, 0)
+	}
+	t.bottomFlex.AddItem(t.hints, 1, 0, false)
+	t.app.SetFocus(t.activeInput())
+	t.updateFocusBorders()
+	t.updateHints()
+}
+
+// confirm shows a yes/no dialog and runs onYes if it is accepted. Focus
+// returns to the previously focused widget either way.
+func (t *tui) confirm(question string, onYes func()) {
+	prevFocus := t.app.GetFocus()
+	modal := tview.NewModal().
+		SetText(question).
+		AddButtons([]string{"Yes", "No"}).
+		SetBackgroundColor(t.theme.inputBgColor).
+		SetTextColor(t.theme.textColor).
+		SetButtonBackgroundColor(t.theme.borderColor).
+		SetButtonTextColor(t.theme.textColor)
+	modal.SetDoneFunc(func(_ int, label string) {
+		t.modalOpen = false
+		t.app.SetRoot(t.mainFlex, true).SetFocus(prevFocus)
+		t.updateFocusBorders()
+		t.updateHints()
+		if label == "Yes" {
+			onYes()
+		}
+	})
+	pages := tview.NewPages().
+		AddPage("main", t.mainFlex, true, true).
+		AddPage("confirm", modal, true, true)
+	t.modalOpen = true
+	t.app.SetRoot(pages, true).SetFocus(modal)
+}
+
+// findView returns the view with the given name, or the active view for an empty name.
+func (t *tui) findView(name string) *client.View {
+	if name == "" {
+		if t.activeViewIndex >= 0 && t.activeViewIndex < len(t.views) {
+			return &t.views[t.activeViewIndex]
+		}
+		return nil
+	}
+	for i := range t.views {
+		if t.views[i].Name == name {
+			return &t.views[i]
+		}
+	}
+	return nil
+}
+
 // cycleFocus cycles the focus between the main UI primitives.
 func (t *tui) cycleFocus(forward bool) {
-	primitives := []tview.Primitive{t.input, t.chatList, t.output, t.logs, t.detailsView}
+	primitives := []tview.Primitive{t.activeInput(), t.chatList, t.output, t.logs, t.detailsView}
 	for i, p := range primitives {
 		if p.HasFocus() {
 			var next int
@@ -234,20 +562,29 @@ func (t *tui) cycleFocus(forward bool) {
 // handleMaximizedViewKeys handles key events when a view is maximized.
 func (t *tui) handleMaximizedViewKeys(event *tcell.EventKey) *tcell.EventKey {
 	currentFocus := t.app.GetFocus()
+	if t.keys["maximize"].matches(event) {
+		if currentFocus == t.logs {
+			t.logsMaximized = false
+			t.app.SetRoot(t.mainFlex, true).SetFocus(t.logs)
+		}
+		if currentFocus == t.output {
+			t.outputMaximized = false
+			t.app.SetRoot(t.mainFlex, true).SetFocus(t.output)
+		}
+		t.updateHints()
+		return nil
+	}
+
 	switch event.Key() {
 	case tcell.KeyRune:
-		if event.Rune() == '`' {
-			if currentFocus == t.logs {
-				t.logsMaximized = false
-				t.app.SetRoot(t.mainFlex, true).SetFocus(t.logs)
-			}
-			if currentFocus == t.output {
-				t.outputMaximized = false
-				t.app.SetRoot(t.mainFlex, true).SetFocus(t.output)
-			}
-			t.updateHints()
-			return nil
+		if currentFocus == t.output {
+			t.handleOutputKeys(event)
 		}
+	case tcell.KeyEscape:
+		if currentFocus == t.output {
+			t.handleOutputKeys(event)
+		}
+		return nil
 	case tcell.KeyCtrlC:
 		t.actionsChan <- client.UserAction{Type: "QUIT"}
 		return nil
@@ -259,6 +596,42 @@ func (t *tui) handleMaximizedViewKeys(event *tcell.EventKey) *tcell.EventKey {
 	return nil
 }
 
+// handleOutputKeys handles key events for the output view.
+// It returns nil if the key was consumed.
+func (t *tui) handleOutputKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape && len(t.searchMatches) > 0 {
+		t.clearSearch()
+		return nil
+	}
+	if event.Key() != tcell.KeyRune {
+		return event
+	}
+
+	switch event.Rune() {
+	case 'o':
+		t.openLastURL()
+	case 'y':
+		t.copyLastMessage(false)
+	case 'Y':
+		t.copyLastMessage(true)
+	case 'w':
+		t.whoisLastMessage()
+	case 'e':
+		t.toggleExpand()
+	case 'p':
+		t.togglePause()
+	case '/':
+		t.openSearch()
+	case 'n':
+		t.nextMatch(-1)
+	case 'N':
+		t.nextMatch(1)
+	default:
+		return event
+	}
+	return nil
+}
+
 // handleChatListKeys handles key events for the chat list view.
 func (t *tui) handleChatListKeys(event *tcell.EventKey) *tcell.EventKey {
 	if key := event.Key(); key == tcell.KeyUp || key == tcell.KeyDown || key == tcell.KeyHome || key == tcell.KeyEnd {
@@ -302,11 +675,18 @@ func (t *tui) handleChatListKeys(event *tcell.EventKey) *tcell.EventKey {
 		t.selectedForGroup = make(map[string]bool)
 		return nil
 	case tcell.KeyDelete:
-		action := "LEAVE_CHAT"
+		action, question := "LEAVE_CHAT", fmt.Sprintf("Leave chat %s?", selectedView.Name)
 		if selectedView.IsGroup {
-			action = "DELETE_GROUP"
+			action, question = "DELETE_GROUP", fmt.Sprintf("Delete group %s?", selectedView.Name)
+		}
+		send := func() {
+			t.actionsChan <- client.UserAction{Type: action, Payload: selectedView.Name}
+		}
+		if t.confirmDeletes {
+			t.confirm(question, send)
+		} else {
+			send()
 		}
-		t.actionsChan <- client.UserAction{Type: action, Payload: selectedView.Name}
 		return nil
 	}
 	return event
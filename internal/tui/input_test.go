@@ -0,0 +1,47 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/lessucettes/strchat-tui/internal/client"
+	"github.com/rivo/tview"
+)
+
+func TestComposeKey(t *testing.T) {
+	if err := client.SetConfigDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	actions := make(chan client.UserAction, 1)
+	tu := &tui{
+		actionsChan: actions,
+		compose:     tview.NewTextArea(),
+		drafts:      make(map[string]string),
+		maxMsgLen:   1000,
+	}
+	tu.applyKeybindings(nil)
+	tu.compose.SetText("first line", true)
+
+	enter := tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
+	if got := tu.composeKey(enter); got != enter {
+		t.Fatalf("plain Enter was consumed, want it passed to the TextArea")
+	}
+	if len(actions) != 0 {
+		t.Fatalf("plain Enter submitted %+v", <-actions)
+	}
+
+	if got := tu.composeKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModAlt)); got != nil {
+		t.Fatalf("Alt+Enter was passed on, want it consumed")
+	}
+	select {
+	case a := <-actions:
+		if a.Type != "SEND_MESSAGE" || a.Payload != "first line" {
+			t.Errorf("Alt+Enter sent %+v, want SEND_MESSAGE of the text", a)
+		}
+	default:
+		t.Fatal("Alt+Enter did not submit the message")
+	}
+	if text := tu.compose.GetText(); text != "" {
+		t.Errorf("compose field holds %q after submitting, want it cleared", text)
+	}
+}
@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// defaultKeybindings maps rebindable actions to their default key descriptors.
+var defaultKeybindings = map[string]string{
+	"focus_chats":  "Alt+C",
+	"focus_output": "Alt+O",
+	"focus_input":  "Alt+I",
+	"focus_logs":   "Alt+L",
+	"focus_info":   "Alt+N",
+	"maximize":     "`",
+	"history_prev": "Ctrl+P",
+	"history_next": "Ctrl+N",
+	"compose":      "Alt+M",
+	"compose_send": "Alt+Enter",
+}
+
+// keyBinding is a parsed key descriptor.
+type keyBinding struct {
+	desc       string
+	key        tcell.Key
+	ch         rune
+	mod        tcell.ModMask
+	ctrlLetter bool // parsed from Ctrl+<letter>, which tcell reports as its own key
+}
+
+// parseKeyBinding parses descriptors like "Alt+C", "Ctrl+P", "F2", "Esc" or "`".
+// Modifiers are Ctrl, Alt and Shift; the final part is a single character or a
+// tcell key name. Letters combined with Alt are case-insensitive.
+func parseKeyBinding(desc string) (keyBinding, error) {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return keyBinding{}, fmt.Errorf("empty key descriptor")
+	}
+
+	parts := strings.Split(desc, "+")
+	name := parts[len(parts)-1]
+	if name == "" && len(parts) > 1 {
+		// "Alt++" binds the plus key itself.
+		parts = parts[:len(parts)-1]
+		name = "+"
+	}
+
+	b := keyBinding{desc: desc}
+	for _, m := range parts[:len(parts)-1] {
+		switch strings.ToLower(strings.TrimSpace(m)) {
+		case "ctrl":
+			b.mod |= tcell.ModCtrl
+		case "alt":
+			b.mod |= tcell.ModAlt
+		case "shift":
+			b.mod |= tcell.ModShift
+		default:
+			return keyBinding{}, fmt.Errorf("unknown modifier %q in %q", m, desc)
+		}
+	}
+
+	if r := []rune(name); len(r) == 1 {
+		ch := r[0]
+		if b.mod&tcell.ModCtrl != 0 {
+			l := unicode.ToLower(ch)
+			if l < 'a' || l > 'z' {
+				return keyBinding{}, fmt.Errorf("ctrl can only be combined with letters in %q", desc)
+			}
+			b.key = tcell.KeyCtrlA + tcell.Key(l-'a')
+			b.ctrlLetter = true
+			return b, nil
+		}
+		if b.mod&tcell.ModAlt != 0 {
+			ch = unicode.ToLower(ch)
+		}
+		b.key = tcell.KeyRune
+		b.ch = ch
+		return b, nil
+	}
+
+	for k, n := range tcell.KeyNames {
+		if strings.EqualFold(n, name) {
+			b.key = k
+			return b, nil
+		}
+	}
+	return keyBinding{}, fmt.Errorf("unknown key %q in %q", name, desc)
+}
+
+// matches reports whether the key event triggers the binding.
+func (b keyBinding) matches(ev *tcell.EventKey) bool {
+	switch {
+	case b.key == tcell.KeyRune:
+		if ev.Key() != tcell.KeyRune {
+			return false
+		}
+		r := ev.Rune()
+		if b.mod&tcell.ModAlt != 0 {
+			r = unicode.ToLower(r)
+		}
+		return r == b.ch && ev.Modifiers()&tcell.ModAlt == b.mod&tcell.ModAlt
+	case b.ctrlLetter:
+		// Terminals differ in whether they also report ModCtrl here. Named
+		// keys sharing these values (Enter is Ctrl+M, Tab is Ctrl+I) are not
+		// ctrlLetter, so their modifiers are still compared below.
+		return ev.Key() == b.key
+	default:
+		return ev.Key() == b.key && ev.Modifiers() == b.mod
+	}
+}
+
+// applyKeybindings rebuilds the key map from the defaults and the user's overrides.
+// Unknown actions and invalid descriptors are logged and ignored.
+func (t *tui) applyKeybindings(custom map[string]string) {
+	keys := make(map[string]keyBinding, len(defaultKeybindings))
+	for action, desc := range defaultKeybindings {
+		b, _ := parseKeyBinding(desc)
+		keys[action] = b
+	}
+	for action, desc := range custom {
+		if _, ok := defaultKeybindings[action]; !ok {
+			log.Printf("Unknown keybinding action %q", action)
+			continue
+		}
+		b, err := parseKeyBinding(desc)
+		if err != nil {
+			log.Printf("Invalid keybinding for %s: %v", action, err)
+			continue
+		}
+		keys[action] = b
+	}
+	t.keys = keys
+}
+
+// keyDesc returns the descriptor bound to an action, for titles and hints.
+func (t *tui) keyDesc(action string) string {
+	return t.keys[action].desc
+}
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseKeyBinding(t *testing.T) {
+	tests := []struct {
+		desc       string
+		key        tcell.Key
+		ch         rune
+		mod        tcell.ModMask
+		ctrlLetter bool
+	}{
+		{"Ctrl+P", tcell.KeyCtrlP, 0, tcell.ModCtrl, true},
+		{"Alt+C", tcell.KeyRune, 'c', tcell.ModAlt, false},
+		{"`", tcell.KeyRune, '`', 0, false},
+		{"Alt+Enter", tcell.KeyEnter, 0, tcell.ModAlt, false},
+		{"F2", tcell.KeyF2, 0, 0, false},
+		{"Esc", tcell.KeyEscape, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			b, err := parseKeyBinding(tt.desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.key != tt.key || b.ch != tt.ch || b.mod != tt.mod || b.ctrlLetter != tt.ctrlLetter {
+				t.Errorf("parseKeyBinding(%q) = key %v ch %q mod %v ctrlLetter %v, want key %v ch %q mod %v ctrlLetter %v",
+					tt.desc, b.key, b.ch, b.mod, b.ctrlLetter, tt.key, tt.ch, tt.mod, tt.ctrlLetter)
+			}
+		})
+	}
+}
+
+func TestParseKeyBindingInvalid(t *testing.T) {
+	for _, desc := range []string{"", "Hyper+X", "Ctrl+1", "NoSuchKey"} {
+		if _, err := parseKeyBinding(desc); err == nil {
+			t.Errorf("parseKeyBinding(%q) succeeded, want an error", desc)
+		}
+	}
+}
+
+func TestKeyBindingMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		desc string
+		ev   *tcell.EventKey
+		want bool
+	}{
+		{"ctrl letter", "Ctrl+P", tcell.NewEventKey(tcell.KeyCtrlP, 0, tcell.ModCtrl), true},
+		{"ctrl letter without reported mod", "Ctrl+P", tcell.NewEventKey(tcell.KeyCtrlP, 0, tcell.ModNone), true},
+		{"other ctrl letter", "Ctrl+P", tcell.NewEventKey(tcell.KeyCtrlN, 0, tcell.ModCtrl), false},
+		{"alt letter", "Alt+C", tcell.NewEventKey(tcell.KeyRune, 'c', tcell.ModAlt), true},
+		{"alt letter upper case", "Alt+C", tcell.NewEventKey(tcell.KeyRune, 'C', tcell.ModAlt), true},
+		{"alt letter without alt", "Alt+C", tcell.NewEventKey(tcell.KeyRune, 'c', tcell.ModNone), false},
+		{"backtick", "`", tcell.NewEventKey(tcell.KeyRune, '`', tcell.ModNone), true},
+		{"backtick with alt", "`", tcell.NewEventKey(tcell.KeyRune, '`', tcell.ModAlt), false},
+		{"alt enter", "Alt+Enter", tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModAlt), true},
+		{"alt enter against plain enter", "Alt+Enter", tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), false},
+		{"f2", "F2", tcell.NewEventKey(tcell.KeyF2, 0, tcell.ModNone), true},
+		{"f2 with ctrl", "F2", tcell.NewEventKey(tcell.KeyF2, 0, tcell.ModCtrl), false},
+		{"esc", "Esc", tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone), true},
+		{"esc against f2", "Esc", tcell.NewEventKey(tcell.KeyF2, 0, tcell.ModNone), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := parseKeyBinding(tt.desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.matches(tt.ev); got != tt.want {
+				t.Errorf("%q matches %s = %v, want %v", tt.desc, tt.ev.Name(), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package tui
+
+import "github.com/gdamore/tcell/v2"
+
+// togglePause holds or resumes the live feed of the output view.
+func (t *tui) togglePause() {
+	if t.paused {
+		t.resumeFeed()
+		return
+	}
+	t.paused = true
+	// Pin the view where it is; tview keeps following the end otherwise.
+	row, col := t.output.GetScrollOffset()
+	t.output.ScrollTo(row, col)
+	t.updateOutputTitle()
+}
+
+// resumeFeed writes the messages held while paused and scrolls to the end.
+func (t *tui) resumeFeed() {
+	t.paused = false
+	for _, msg := range t.pausedMsgs {
+		t.appendMessage(msg)
+	}
+	t.pausedMsgs = nil
+	t.output.ScrollToEnd()
+	t.updateOutputTitle()
+}
+
+// followOutput keeps the output view at its end, unless it is maximized,
+// paused or scrolled up by the user to read older messages.
+func (t *tui) followOutput() {
+	if t.outputMaximized || t.paused || !t.outputAtEnd {
+		return
+	}
+	t.output.ScrollToEnd()
+}
+
+// checkOutputScroll records after each draw whether the output view shows
+// its last line, so new messages only follow the end while it is visible.
+// It also re-wraps messages when the width changed under hanging indent.
+func (t *tui) checkOutputScroll(screen tcell.Screen) {
+	row, _ := t.output.GetScrollOffset()
+	_, _, _, height := t.output.GetInnerRect()
+	t.outputAtEnd = row+height >= t.output.GetWrappedLineCount()
+	if t.outputAtEnd && t.unseenBelow > 0 {
+		t.unseenBelow = 0
+		go t.app.QueueUpdateDraw(t.updateOutputTitle)
+	}
+	if _, _, width, _ := t.output.GetInnerRect(); t.hangingIndent && width != t.wrapWidth {
+		t.wrapWidth = width
+		go t.app.QueueUpdateDraw(t.rerenderAll)
+	}
+}
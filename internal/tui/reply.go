@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+const (
+	maxQuotedMsgs = 500
+	maxQuoteWidth = 60
+)
+
+// quotedMsg is what a reply preview shows of a recently received message.
+type quotedMsg struct {
+	nick    string
+	content string
+}
+
+// rememberQuote records a message by its full ID so replies to it can show
+// a preview. Short IDs are easy to forge, so they are never used as keys.
+// The oldest entries are dropped beyond maxQuotedMsgs.
+func (t *tui) rememberQuote(event client.DisplayEvent) {
+	if event.FullID == "" {
+		return
+	}
+	if _, ok := t.quotes[event.FullID]; !ok {
+		t.quoteOrder = append(t.quoteOrder, event.FullID)
+		if len(t.quoteOrder) > maxQuotedMsgs {
+			delete(t.quotes, t.quoteOrder[0])
+			t.quoteOrder = t.quoteOrder[1:]
+		}
+	}
+	t.quotes[event.FullID] = quotedMsg{nick: event.Nick, content: event.Content}
+}
+
+// replyPreview returns the line shown above a reply to the event with the
+// full ID id: the quoted message if it is known, otherwise its short ID.
+func (t *tui) replyPreview(id string) string {
+	q, ok := t.quotes[id]
+	if !ok {
+		return fmt.Sprintf("[%s]↳ replying to %s[-]", t.theme.logInfoColor, shortID(id))
+	}
+	content := strings.Join(strings.Fields(q.content), " ")
+	if uniseg.StringWidth(content) > maxQuoteWidth {
+		var b strings.Builder
+		width := 0
+		g := uniseg.NewGraphemes(content)
+		for g.Next() {
+			if width += g.Width(); width > maxQuoteWidth-1 {
+				break
+			}
+			b.WriteString(g.Str())
+		}
+		content = b.String() + "…"
+	}
+	return fmt.Sprintf("[%s]↳ %s: %s[-]", t.theme.logInfoColor, tview.Escape(q.nick), tview.Escape(content))
+}
+
+// shortID returns the last 4 chars of an event ID, as shown with messages.
+func shortID(id string) string {
+	if len(id) <= 4 {
+		return id
+	}
+	return id[len(id)-4:]
+}
@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+// setupSearch configures the handlers of the scrollback search field.
+func (t *tui) setupSearch() {
+	t.searchInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			t.runSearch(t.searchInput.GetText())
+		}
+		t.closeSearch()
+	})
+
+	t.searchInput.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		if ev.Key() == tcell.KeyCtrlT {
+			t.searchCaseSensitive = !t.searchCaseSensitive
+			t.updateSearchLabel()
+			return nil
+		}
+		return ev
+	})
+}
+
+// updateSearchLabel shows whether the search is case-sensitive.
+func (t *tui) updateSearchLabel() {
+	if t.searchCaseSensitive {
+		t.searchInput.SetLabel("Search (Ctrl+T: case on) > ")
+	} else {
+		t.searchInput.SetLabel("Search (Ctrl+T: case off) > ")
+	}
+}
+
+// searchParent returns the flex that currently shows the hints line.
+func (t *tui) searchParent() *tview.Flex {
+	if t.outputMaximized {
+		return t.maximizedOutputFlex
+	}
+	return t.bottomFlex
+}
+
+// openSearch replaces the hints line with the search field and focuses it.
+func (t *tui) openSearch() {
+	parent := t.searchParent()
+	parent.RemoveItem(t.hints)
+	parent.AddItem(t.searchInput, 1, 0, true)
+	t.searchInput.SetText("")
+	t.app.SetFocus(t.searchInput)
+}
+
+// closeSearch restores the hints line and returns focus to the output view.
+func (t *tui) closeSearch() {
+	parent := t.searchParent()
+	parent.RemoveItem(t.searchInput)
+	parent.AddItem(t.hints, 1, 0, false)
+	t.app.SetFocus(t.output)
+	t.updateFocusBorders()
+	t.updateHints()
+}
+
+// runSearch collects the messages matching query and jumps to the most recent one.
+func (t *tui) runSearch(query string) {
+	if query == "" {
+		t.clearSearch()
+		return
+	}
+	if !t.searchCaseSensitive {
+		query = strings.ToLower(query)
+	}
+
+	t.searchMatches = t.searchMatches[:0]
+	for _, m := range t.renderedMsgs {
+		text := m.text
+		if !t.searchCaseSensitive {
+			text = strings.ToLower(text)
+		}
+		if strings.Contains(text, query) {
+			t.searchMatches = append(t.searchMatches, m.region)
+		}
+	}
+
+	if len(t.searchMatches) == 0 {
+		t.output.Highlight()
+		t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "No matches for: " + query})
+		return
+	}
+	t.searchIdx = len(t.searchMatches) - 1
+	t.showMatch()
+}
+
+// nextMatch moves to an older (delta < 0) or newer (delta > 0) match.
+func (t *tui) nextMatch(delta int) {
+	if len(t.searchMatches) == 0 {
+		return
+	}
+	t.searchIdx = (t.searchIdx + delta + len(t.searchMatches)) % len(t.searchMatches)
+	t.showMatch()
+}
+
+func (t *tui) showMatch() {
+	t.output.Highlight(t.searchMatches[t.searchIdx]).ScrollToHighlight()
+	t.handleLogMessage(client.DisplayEvent{
+		Type:    "STATUS",
+		Content: fmt.Sprintf("Match %d/%d", t.searchIdx+1, len(t.searchMatches)),
+	})
+}
+
+// clearSearch removes the search highlight.
+func (t *tui) clearSearch() {
+	t.searchMatches = nil
+	t.searchIdx = 0
+	t.output.Highlight()
+}
+
+// toggleExpand expands or re-truncates the message of the current search
+// match, or else the latest truncated message.
+func (t *tui) toggleExpand() {
+	var target *messageLine
+	if hl := t.output.GetHighlights(); len(hl) > 0 {
+		for _, m := range t.renderedMsgs {
+			if m.region == hl[0] {
+				target = m.msg
+				break
+			}
+		}
+	} else if t.truncateDisplay > 0 {
+		for i := len(t.renderedMsgs) - 1; i >= 0; i-- {
+			m := t.renderedMsgs[i].msg
+			if !m.expanded && graphemeLen(m.event.Content) > t.truncateDisplay {
+				target = m
+				break
+			}
+		}
+	}
+	if target == nil {
+		t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "No truncated message to expand."})
+		return
+	}
+	target.expanded = !target.expanded
+	t.rerender(target)
+}
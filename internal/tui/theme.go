@@ -1,6 +1,22 @@
 package tui
 
-import "github.com/gdamore/tcell/v2"
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+const (
+	customThemeName     = "custom"
+	customThemeFileName = "theme.json"
+)
 
 // theme holds the color definitions for the application's UI.
 type theme struct {
@@ -13,6 +29,8 @@ type theme struct {
 	logInfoColor    tcell.Color
 	logWarnColor    tcell.Color
 	logErrorColor   tcell.Color
+	highlightColor  tcell.Color
+	ownMsgColor     tcell.Color
 	nickPalette     []string
 }
 
@@ -27,6 +45,8 @@ var defaultTheme = &theme{
 	logInfoColor:    tcell.ColorGrey,
 	logWarnColor:    tcell.ColorYellow,
 	logErrorColor:   tcell.ColorRed,
+	highlightColor:  tcell.ColorOrange,
+	ownMsgColor:     tcell.ColorLime,
 	nickPalette: []string{
 		"[#33ccff]", // Cyan
 		"[#ff00ff]", // Magenta
@@ -47,7 +67,146 @@ var monochromeTheme = &theme{
 	logInfoColor:    tcell.ColorWhite,
 	logWarnColor:    tcell.ColorWhite,
 	logErrorColor:   tcell.ColorWhite,
+	highlightColor:  tcell.ColorWhite,
+	ownMsgColor:     tcell.ColorWhite,
 	nickPalette: []string{
 		"[white]",
 	},
 }
+
+// solarizedTheme is a light theme based on the Solarized palette.
+var solarizedTheme = &theme{
+	backgroundColor: tcell.NewHexColor(0xfdf6e3),
+	textColor:       tcell.NewHexColor(0x657b83),
+	borderColor:     tcell.NewHexColor(0x93a1a1),
+	titleColor:      tcell.NewHexColor(0x268bd2),
+	inputBgColor:    tcell.NewHexColor(0xeee8d5),
+	inputTextColor:  tcell.NewHexColor(0x586e75),
+	logInfoColor:    tcell.NewHexColor(0x93a1a1),
+	logWarnColor:    tcell.NewHexColor(0xb58900),
+	logErrorColor:   tcell.NewHexColor(0xdc322f),
+	highlightColor:  tcell.NewHexColor(0xd33682),
+	ownMsgColor:     tcell.NewHexColor(0x586e75),
+	nickPalette: []string{
+		"[#268bd2]", // Blue
+		"[#d33682]", // Magenta
+		"[#2aa198]", // Cyan
+		"[#859900]", // Green
+		"[#cb4b16]", // Orange
+	},
+}
+
+// amberTheme is a high-contrast amber-on-black theme.
+var amberTheme = &theme{
+	backgroundColor: tcell.ColorBlack,
+	textColor:       tcell.NewHexColor(0xffb000),
+	borderColor:     tcell.NewHexColor(0x805800),
+	titleColor:      tcell.NewHexColor(0xffcc00),
+	inputBgColor:    tcell.NewHexColor(0x2a1a00),
+	inputTextColor:  tcell.NewHexColor(0xffb000),
+	logInfoColor:    tcell.NewHexColor(0xb07800),
+	logWarnColor:    tcell.NewHexColor(0xffd75f),
+	logErrorColor:   tcell.NewHexColor(0xff5f00),
+	highlightColor:  tcell.NewHexColor(0xffffaf),
+	ownMsgColor:     tcell.NewHexColor(0xffb000),
+	nickPalette: []string{
+		"[#ffb000]",
+		"[#ffd75f]",
+		"[#ff8700]",
+		"[#ffaf5f]",
+		"[#d78700]",
+	},
+}
+
+// builtinThemes maps theme names accepted by /theme to their definitions.
+var builtinThemes = map[string]*theme{
+	"default":    defaultTheme,
+	"monochrome": monochromeTheme,
+	"solarized":  solarizedTheme,
+	"amber":      amberTheme,
+}
+
+// themeNames returns the sorted names of all built-in themes.
+func themeNames() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeFile is the JSON representation of a custom theme. Colors are
+// hex strings like "#33ccff" or tcell color names.
+type themeFile struct {
+	Background  string   `json:"background"`
+	Text        string   `json:"text"`
+	Border      string   `json:"border"`
+	Title       string   `json:"title"`
+	InputBg     string   `json:"input_bg"`
+	InputText   string   `json:"input_text"`
+	LogInfo     string   `json:"log_info"`
+	LogWarn     string   `json:"log_warn"`
+	LogError    string   `json:"log_error"`
+	Highlight   string   `json:"highlight"`
+	OwnMessage  string   `json:"own_message"`
+	NickPalette []string `json:"nick_palette"`
+}
+
+// loadCustomTheme reads theme.json from the app config dir and registers it
+// as the "custom" theme. Invalid colors fall back to the default theme.
+func loadCustomTheme() (*theme, error) {
+	dir, err := client.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, customThemeFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var tf themeFile
+	if err := json.Unmarshal(data, &tf); err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", customThemeFileName, err)
+	}
+
+	color := func(field, value string, fallback tcell.Color) tcell.Color {
+		if value == "" {
+			return fallback
+		}
+		c := tcell.GetColor(value)
+		if c == tcell.ColorDefault {
+			log.Printf("Invalid color %q for %s in %s, using default", value, field, customThemeFileName)
+			return fallback
+		}
+		return c
+	}
+
+	th := &theme{
+		backgroundColor: color("background", tf.Background, defaultTheme.backgroundColor),
+		textColor:       color("text", tf.Text, defaultTheme.textColor),
+		borderColor:     color("border", tf.Border, defaultTheme.borderColor),
+		titleColor:      color("title", tf.Title, defaultTheme.titleColor),
+		inputBgColor:    color("input_bg", tf.InputBg, defaultTheme.inputBgColor),
+		inputTextColor:  color("input_text", tf.InputText, defaultTheme.inputTextColor),
+		logInfoColor:    color("log_info", tf.LogInfo, defaultTheme.logInfoColor),
+		logWarnColor:    color("log_warn", tf.LogWarn, defaultTheme.logWarnColor),
+		logErrorColor:   color("log_error", tf.LogError, defaultTheme.logErrorColor),
+		highlightColor:  color("highlight", tf.Highlight, defaultTheme.highlightColor),
+		ownMsgColor:     color("own_message", tf.OwnMessage, defaultTheme.ownMsgColor),
+	}
+	for _, p := range tf.NickPalette {
+		c := tcell.GetColor(p)
+		if c == tcell.ColorDefault {
+			log.Printf("Invalid nick color %q in %s, skipping", p, customThemeFileName)
+			continue
+		}
+		th.nickPalette = append(th.nickPalette, fmt.Sprintf("[#%06x]", c.Hex()))
+	}
+	if len(th.nickPalette) == 0 {
+		th.nickPalette = defaultTheme.nickPalette
+	}
+
+	builtinThemes[customThemeName] = th
+	return th, nil
+}
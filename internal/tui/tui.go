@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"os"
+	"regexp"
+	"runtime"
 	"slices"
 	"strings"
 	"time"
@@ -29,22 +33,55 @@ type tui struct {
 	output              *tview.TextView
 	maximizedOutputFlex *tview.Flex
 	input               *tview.InputField
+	compose             *tview.TextArea
 	hints               *tview.TextView
+	statusLine          *tview.TextView
+	bottomFlex          *tview.Flex
+	searchInput         *tview.InputField
 
 	// UI State
 
 	logsMaximized   bool
 	outputMaximized bool
+	composeMode     bool
 	narrowMode      bool
+	keys            map[string]keyBinding
+	keybindings     map[string]string
+	mentionPending  bool
+	bellOnMention   bool
+	dnd             bool      // do not disturb: no bells or mention markers
+	dndUntil        time.Time // zero while DND has no expiry
+	dndGen          int       // invalidates pending DND expiry timers
+	powProgress     string    // latest PoW mining progress, empty when idle
+	powSpin         int       // spinner frame of the mining indicator
+	disableURLOpen  bool
+	confirmDeletes  bool
+	nickColors      string
+	ownNickByPubKey bool
+	modalOpen       bool
 	theme           *theme
+	themeName       string
+	version         string // build version shown by /version
+	screen          tcell.Screen
+	screenWrap      *colorScreen
+	colorCount      int  // colors the terminal supports, 0 until the first draw
+	forceNoColor    bool // set by NO_COLOR or --no-color, overrides the config
 
 	// App Data
 
 	views            []client.View
 	relays           []client.RelayInfo
 	selectedForGroup map[string]bool
+	unread           map[string]int
 	activeViewIndex  int
 	nick             string
+	pow              int
+	maxMsgLen        int
+	truncateDisplay  int
+	hangingIndent    bool   // continuation lines of a message align under its content
+	messageLayout    string // client.LayoutCompact or client.LayoutExpanded
+	relaySort        string // order of the Info pane's relay list, see client.RelaySortURL
+	wrapWidth        int    // output width messages were last wrapped for
 
 	// Input-specific state
 
@@ -52,26 +89,81 @@ type tui struct {
 	recentRecipients  []string
 	rrIdx             int
 	lastNickQuery     string
+	drafts            map[string]string // unsent input per view name
+	inputHistory      []string          // submitted lines, oldest first
+	histIdx           int               // recalled inputHistory entry, -1 when not navigating
+
+	// Output-specific state
+
+	recentURLs  []string
+	lastMessage *client.DisplayEvent
+	paused      bool                    // feed is on hold: new messages wait in pausedMsgs
+	outputAtEnd bool                    // the last draw showed the end of the output view
+	unseenBelow int                     // messages added below while scrolled up
+	pausedMsgs  []*messageLine          // messages received while paused, oldest first
+	pendingMsgs map[string]*messageLine // own messages awaiting delivery, by LocalID
+	quotes      map[string]quotedMsg    // recent messages by full ID, for reply previews
+	quoteOrder  []string                // quotes keys, oldest first
+
+	// Search state
+
+	renderedMsgs        []renderedMsg
+	msgCounter          int
+	searchMatches       []string
+	searchIdx           int
+	searchCaseSensitive bool
+}
+
+// renderedMsg links a region id in the output view to its searchable text
+// and the message it displays.
+type renderedMsg struct {
+	region string
+	text   string
+	msg    *messageLine
+}
+
+// messageLine is a rendered message that can be re-rendered in place.
+type messageLine struct {
+	region   string
+	event    client.DisplayEvent
+	inGroup  bool
+	status   string
+	quote    string // reply preview shown above the message
+	expanded bool   // show the full content even when truncateDisplay is set
 }
 
 // New creates and initializes the entire TUI application.
 func New(actions chan<- client.UserAction, events <-chan client.DisplayEvent) *tui {
 	t := &tui{
 		app:               tview.NewApplication(),
+		outputAtEnd:       true,
 		actionsChan:       actions,
 		logsMaximized:     false,
 		outputMaximized:   false,
 		views:             []client.View{},
 		relays:            []client.RelayInfo{},
 		selectedForGroup:  make(map[string]bool),
+		unread:            make(map[string]int),
+		pendingMsgs:       make(map[string]*messageLine),
+		quotes:            make(map[string]quotedMsg),
+		drafts:            make(map[string]string),
 		activeViewIndex:   0,
 		completionEntries: []string{},
 		recentRecipients:  []string{},
 		rrIdx:             -1,
+		histIdx:           -1,
 		lastNickQuery:     "",
 		theme:             defaultTheme,
+		themeName:         "default",
+		maxMsgLen:         client.DefaultMaxMsgLen,
+		screenWrap:        &colorScreen{bg: defaultTheme.backgroundColor},
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		t.NoColor()
 	}
 
+	t.applyKeybindings(nil)
+	t.loadInputHistory()
 	t.setupViews()
 	t.setupHandlers()
 	t.updateInputLabel()
@@ -80,6 +172,12 @@ func New(actions chan<- client.UserAction, events <-chan client.DisplayEvent) *t
 	t.updateHints()
 	t.updateDetailsView()
 
+	if _, err := loadCustomTheme(); err == nil {
+		t.setTheme(customThemeName)
+	} else if !os.IsNotExist(err) {
+		log.Printf("Could not load custom theme: %v", err)
+	}
+
 	go t.listenForEvents(events)
 
 	return t
@@ -97,19 +195,36 @@ func (lw *logWriter) Write(p []byte) (int, error) {
 	return fmt.Fprintf(lw.textViewWriter, "\n[%s][%s] %s[-]", lw.getColor(), ts, msg)
 }
 
-// Widget titles.
+// Widget titles. The focus key of each pane is appended, or shown alone in narrow mode.
 const (
-	titleLogs     = "Logs (Alt+L)"
-	titleChats    = "Chats (Alt+C)"
-	titleInfo     = "Info (Alt+N)"
-	titleMessages = "Messages (Alt+O)"
-	titleInput    = "Input (Alt+I)"
-
-	titleLogsShort     = "Alt+L"
-	titleChatsShort    = "Alt+C"
-	titleInfoShort     = "Alt+N"
-	titleMessagesShort = "Alt+O"
-	titleInputShort    = "Alt+I"
+	titleLogs     = "Logs"
+	titleChats    = "Chats"
+	titleInfo     = "Info"
+	titleMessages = "Messages"
+	titleInput    = "Input"
+	titleCompose  = "Compose"
+)
+
+// Heights of the bottom area, including the hints line.
+const (
+	inputHeight   = 4
+	composeHeight = 10
+)
+
+// Message rendering.
+const (
+	minWrapWidth   = 20   // narrowest text column hanging indent wraps messages to
+	expandedIndent = "  " // indents content below the header in the expanded layout
+)
+
+// regionTagRe matches the opening tag of a message region, see appendMessage.
+var regionTagRe = regexp.MustCompile(`\["(m[0-9]+)"\]`)
+
+// Scrollback of the output view. Past maxRenderedMsgs messages, the oldest
+// scrollbackTrim of them are dropped at once.
+const (
+	maxRenderedMsgs = 2000
+	scrollbackTrim  = 200
 )
 
 // setupViews creates and configures all the visual primitives of the TUI.
@@ -127,13 +242,49 @@ func (t *tui) applyTheme() {
 	tview.Styles.TitleColor = t.theme.titleColor
 }
 
+// setTheme switches to a built-in theme and restyles the existing widgets.
+// Messages that are already rendered keep their colors.
+func (t *tui) setTheme(name string) bool {
+	th, ok := builtinThemes[name]
+	if !ok {
+		return false
+	}
+	th = t.fitTheme(th)
+	t.theme = th
+	t.themeName = name
+	t.screenWrap.bg = th.backgroundColor
+	t.applyTheme()
+
+	for _, box := range []*tview.Box{t.logs.Box, t.chatList.Box, t.detailsView.Box, t.output.Box, t.input.Box, t.compose.Box, t.hints.Box, t.statusLine.Box} {
+		box.SetBackgroundColor(th.backgroundColor).SetTitleColor(th.titleColor)
+	}
+	for _, tv := range []*tview.TextView{t.logs, t.detailsView, t.output, t.hints, t.statusLine} {
+		tv.SetTextColor(th.textColor)
+	}
+	t.chatList.SetMainTextColor(th.textColor).SetSelectedBackgroundColor(th.borderColor)
+	for _, in := range []*tview.InputField{t.input, t.searchInput} {
+		in.SetLabelStyle(tcell.StyleDefault.Foreground(th.titleColor)).
+			SetFieldBackgroundColor(th.inputBgColor).
+			SetFieldTextColor(th.inputTextColor)
+	}
+	t.compose.SetTextStyle(tcell.StyleDefault.Background(th.inputBgColor).Foreground(th.inputTextColor))
+
+	t.updateFocusBorders()
+	t.updateHints()
+	t.updateOutputTitle()
+	t.updateChatList()
+	t.updateDetailsView()
+	t.updateStatusLine()
+	return true
+}
+
 // initViews initializes all the individual widgets for the TUI.
 func (t *tui) initViews() {
 	t.logs = tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true).
 		SetChangedFunc(func() { t.app.Draw() })
-	t.logs.SetBorder(true).SetTitle(titleLogs).SetTitleAlign(tview.AlignLeft)
+	t.logs.SetBorder(true).SetTitleAlign(tview.AlignLeft)
 	customWriter := &logWriter{
 		textViewWriter: tview.ANSIWriter(t.logs),
 		getColor:       func() tcell.Color { return t.theme.logInfoColor },
@@ -144,28 +295,29 @@ func (t *tui) initViews() {
 	t.chatList = tview.NewList().
 		ShowSecondaryText(false).
 		SetSelectedBackgroundColor(t.theme.borderColor)
-	t.chatList.SetBorder(true).SetTitle(titleChats).SetTitleAlign(tview.AlignLeft)
+	t.chatList.SetBorder(true).SetTitleAlign(tview.AlignLeft)
 
 	t.detailsView = tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true).
 		SetChangedFunc(func() { t.app.Draw() })
-	t.detailsView.SetBorder(true).SetTitle(titleInfo).SetTitleAlign(tview.AlignLeft)
+	t.detailsView.SetBorder(true).SetTitleAlign(tview.AlignLeft)
 
 	t.output = tview.NewTextView().
 		SetDynamicColors(true).
+		SetRegions(true).
 		SetScrollable(true).
 		SetChangedFunc(func() { t.app.Draw() })
-	t.output.SetBorder(true).SetTitle(titleMessages).SetTitleAlign(tview.AlignLeft)
+	t.output.SetBorder(true).SetTitleAlign(tview.AlignLeft)
 
 	t.input = tview.NewInputField().
 		SetLabelStyle(tcell.StyleDefault.Foreground(t.theme.titleColor)).
 		SetFieldBackgroundColor(t.theme.inputBgColor).
 		SetFieldTextColor(t.theme.inputTextColor)
-	t.input.SetBorder(true).SetTitle(titleInput).SetTitleAlign(tview.AlignLeft)
+	t.input.SetBorder(true).SetTitleAlign(tview.AlignLeft)
 	t.input.SetAutocompleteFunc(t.handleAutocomplete)
 	t.input.SetAcceptanceFunc(func(textToCheck string, lastChar rune) bool {
-		return graphemeLen(textToCheck) <= client.MaxMsgLen
+		return graphemeLen(textToCheck) <= t.maxMsgLen
 	})
 	t.input.SetChangedFunc(func(text string) {
 		nick, complete := extractNickPrefix(text)
@@ -185,9 +337,24 @@ func (t *tui) initViews() {
 		}
 	})
 
+	t.compose = tview.NewTextArea().
+		SetTextStyle(tcell.StyleDefault.Background(t.theme.inputBgColor).Foreground(t.theme.inputTextColor))
+	t.compose.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+
 	t.hints = tview.NewTextView().
 		SetDynamicColors(true).
 		SetTextAlign(tview.AlignLeft)
+
+	t.statusLine = tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignLeft)
+
+	t.searchInput = tview.NewInputField().
+		SetLabelStyle(tcell.StyleDefault.Foreground(t.theme.titleColor)).
+		SetFieldBackgroundColor(t.theme.inputBgColor).
+		SetFieldTextColor(t.theme.inputTextColor)
+	t.updateSearchLabel()
+	t.updateTitles()
 }
 
 // initLayout composes the widgets into the final layout and sets up responsiveness.
@@ -206,17 +373,15 @@ func (t *tui) initLayout() {
 
 	const narrowWidth = 100
 	t.app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		t.screen = screen
+		t.checkColors(screen)
 		w, _ := screen.Size()
 		contentGrid.Clear()
 
 		if w < narrowWidth {
 			if !t.narrowMode {
 				t.narrowMode = true
-				t.logs.SetTitle(titleLogsShort)
-				t.output.SetTitle(titleMessagesShort)
-				t.chatList.SetTitle(titleChatsShort)
-				t.detailsView.SetTitle(titleInfoShort)
-				t.input.SetTitle(titleInputShort)
+				t.updateTitles()
 				t.input.SetLabel("> ")
 			}
 			contentGrid.SetRows(0, 5)
@@ -226,11 +391,7 @@ func (t *tui) initLayout() {
 		} else {
 			if t.narrowMode {
 				t.narrowMode = false
-				t.logs.SetTitle(titleLogs)
-				t.output.SetTitle(titleMessages)
-				t.chatList.SetTitle(titleChats)
-				t.detailsView.SetTitle(titleInfo)
-				t.input.SetTitle(titleInput)
+				t.updateTitles()
 				t.updateInputLabel()
 			}
 			contentGrid.SetRows(0)
@@ -240,8 +401,9 @@ func (t *tui) initLayout() {
 		}
 		return false
 	})
+	t.app.SetAfterDrawFunc(t.checkOutputScroll)
 
-	bottomFlex := tview.NewFlex().
+	t.bottomFlex = tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(t.input, 0, 1, true).
 		AddItem(t.hints, 1, 0, false)
@@ -250,7 +412,8 @@ func (t *tui) initLayout() {
 		SetDirection(tview.FlexRow).
 		AddItem(t.logs, 3, 0, false).
 		AddItem(contentGrid, 0, 1, false).
-		AddItem(bottomFlex, 4, 0, true)
+		AddItem(t.statusLine, 1, 0, false).
+		AddItem(t.bottomFlex, inputHeight, 0, true)
 
 	t.maximizedLogsFlex = tview.NewFlex().
 		SetDirection(tview.FlexRow).
@@ -265,12 +428,22 @@ func (t *tui) initLayout() {
 
 // handleAutocomplete provides completion entries for the input field.
 func (t *tui) handleAutocomplete(currentText string) []string {
+	if matches := commandMatches(currentText); len(matches) > 0 {
+		// A complete command keeps Enter free to submit it.
+		if slices.Contains(commandNames, currentText) {
+			return nil
+		}
+		return matches
+	}
+
 	trimmed := strings.TrimSpace(currentText)
 
 	if strings.HasPrefix(trimmed, "/block ") ||
 		strings.HasPrefix(trimmed, "/unblock ") ||
+		strings.HasPrefix(trimmed, "/whois ") ||
 		strings.HasPrefix(trimmed, "/b ") ||
-		strings.HasPrefix(trimmed, "/ub ") {
+		strings.HasPrefix(trimmed, "/ub ") ||
+		strings.HasPrefix(trimmed, "/w ") {
 		parts := strings.SplitN(currentText, " ", 2)
 		if len(parts) < 2 {
 			return nil
@@ -314,6 +487,8 @@ func (t *tui) listenForEvents(events <-chan client.DisplayEvent) {
 			switch event.Type {
 			case "NEW_MESSAGE":
 				t.handleNewMessage(event)
+			case "MESSAGE_STATUS":
+				t.handleMessageStatus(event)
 			case "INFO":
 				t.handleInfoMessage(event)
 			case "STATUS", "ERROR":
@@ -322,6 +497,8 @@ func (t *tui) listenForEvents(events <-chan client.DisplayEvent) {
 				t.handleStateUpdate(event)
 			case "RELAYS_UPDATE":
 				t.handleRelaysUpdate(event)
+			case "POW_PROGRESS":
+				t.handlePoWProgress(event)
 			case "NICK_COMPLETION_RESULT":
 				t.handleNickCompletion(event)
 			}
@@ -347,50 +524,402 @@ func (t *tui) handleNewMessage(event client.DisplayEvent) {
 			showMessage = true
 		}
 	}
+	if !event.IsOwnMessage && event.Dropped == "" {
+		level := t.notifyLevel(event.Chat)
+		mentioned := t.nick != "" && strings.Contains(event.Content, "@"+t.nick)
+		if !showMessage && level != client.NotifyNone {
+			t.unread[event.Chat]++
+			t.updateChatList()
+		}
+		// bell_on_mention only enables the bell for mentions; a view set to
+		// notify on every message rings regardless.
+		if level == client.NotifyAll || (level == client.NotifyMentions && mentioned && t.bellOnMention) {
+			t.notifyMention()
+		}
+	}
 	if showMessage {
-		nickColorTag := pubkeyToColor(event.FullPubKey, t.theme.nickPalette)
-
-		ownColorTag := fmt.Sprintf("[%s]", t.theme.inputTextColor)
-		ownNickTag := fmt.Sprintf("[%s::b]", t.theme.inputTextColor)
-
-		mention := "@" + t.nick
-		content := event.Content
-		if t.nick != "" && strings.Contains(content, mention) {
-			content = strings.ReplaceAll(
-				content,
-				mention,
-				fmt.Sprintf("[%s::b]%s[-::-]", t.theme.inputTextColor, mention),
-			)
+		if event.IsOwnMessage && t.paused {
+			t.resumeFeed()
+		}
+		msg := &messageLine{event: event, inGroup: activeView.IsGroup, status: client.MsgStatusPending}
+		if event.ReplyTo != "" {
+			msg.quote = t.replyPreview(event.ReplyTo)
 		}
+		if event.IsOwnMessage && event.LocalID != "" {
+			t.pendingMsgs[event.LocalID] = msg
+		}
+		if t.paused {
+			t.pausedMsgs = append(t.pausedMsgs, msg)
+			t.updateOutputTitle()
+		} else {
+			t.appendMessage(msg)
+			if !t.outputAtEnd {
+				t.unseenBelow++
+				t.updateOutputTitle()
+			}
+		}
+	}
+	if event.Dropped == "" {
+		t.rememberQuote(event)
+	}
+	t.followOutput()
+}
+
+// appendMessage writes a message to the end of the output view.
+func (t *tui) appendMessage(msg *messageLine) {
+	event := msg.event
+	if event.Dropped == "" {
+		t.rememberURLs(event.Content)
+		t.lastMessage = &msg.event
+	}
+
+	t.msgCounter++
+	msg.region = fmt.Sprintf("m%d", t.msgCounter)
+	t.renderedMsgs = append(t.renderedMsgs, renderedMsg{
+		region: msg.region,
+		text:   fmt.Sprintf("%s#%s> %s", event.Nick, event.ShortPubKey, event.Content),
+		msg:    msg,
+	})
+	fmt.Fprintf(t.output, "\n[\"%s\"]%s[\"\"]", msg.region, t.formatMessage(msg))
+	if len(t.renderedMsgs) > maxRenderedMsgs {
+		t.trimScrollback()
+	}
+}
+
+// trimScrollback drops the oldest scrollbackTrim messages, and whatever was
+// printed before them, from the output view.
+func (t *tui) trimScrollback() {
+	keep := t.renderedMsgs[scrollbackTrim]
+	text := t.output.GetText(false)
+	start := strings.Index(text, fmt.Sprintf("[\"%s\"]", keep.region))
+	if start < 0 {
+		return
+	}
+	dropped := make(map[string]bool, scrollbackTrim)
+	for _, r := range t.renderedMsgs[:scrollbackTrim] {
+		dropped[r.region] = true
+	}
+	t.renderedMsgs = slices.Delete(t.renderedMsgs, 0, scrollbackTrim)
+	t.output.SetText(text[start:])
 
-		label := ""
-		activeView := t.views[t.activeViewIndex]
-		if activeView.IsGroup {
-			label = fmt.Sprintf("[%s]%s[-] ", t.theme.titleColor, event.Chat)
+	if len(t.searchMatches) > 0 {
+		current := t.searchMatches[t.searchIdx]
+		t.searchMatches = slices.DeleteFunc(t.searchMatches, func(region string) bool { return dropped[region] })
+		t.searchIdx = max(slices.Index(t.searchMatches, current), 0)
+	}
+}
+
+// formatMessage renders a message line without its region tags. Everything
+// a remote user controls is escaped, so it can never open or close a region
+// or color tag.
+func (t *tui) formatMessage(msg *messageLine) string {
+	event := msg.event
+	nick := tview.Escape(event.Nick)
+
+	mention := tview.Escape("@" + t.nick)
+	content, more := event.Content, 0
+	if t.truncateDisplay > 0 && !msg.expanded {
+		content, more = truncateGraphemes(content, t.truncateDisplay)
+	}
+	content = t.highlight(content, event.Highlights)
+	if more > 0 {
+		content += fmt.Sprintf("[%s]… (%d more, e: expand)[-]", t.theme.logInfoColor, more)
+	}
+	if t.nick != "" && event.Dropped == "" && strings.Contains(content, mention) {
+		content = strings.ReplaceAll(
+			content,
+			mention,
+			fmt.Sprintf("[%s::b]%s[-::-]", t.theme.inputTextColor, mention),
+		)
+	}
+
+	label := ""
+	if msg.inGroup {
+		label = fmt.Sprintf("[%s]%s[-] ", t.theme.titleColor, tview.Escape(event.Chat))
+	}
+
+	quote := ""
+	if msg.quote != "" {
+		quote = msg.quote + "\n"
+	}
+
+	skew := ""
+	if event.Skew != 0 {
+		skew = fmt.Sprintf(" [skew %+ds]", event.Skew)
+	}
+
+	if event.Dropped != "" {
+		return quote + t.layoutMessage(
+			fmt.Sprintf("%s[%s]%s#%s[-]", label, t.theme.logInfoColor, nick, event.ShortPubKey),
+			fmt.Sprintf("[%s]%s [dropped: %s][-]", t.theme.logInfoColor, content, event.Dropped),
+			fmt.Sprintf("[%s][%s %s]%s[-]", t.theme.logInfoColor, event.ID, event.Timestamp, skew),
+		)
+	}
+
+	if !event.IsOwnMessage {
+		nickColorTag := t.nickColor(event.FullPubKey)
+		return quote + t.layoutMessage(
+			fmt.Sprintf("%s%s%s[-::-]#%s", label, nickColorTag, nick, event.ShortPubKey),
+			content,
+			fmt.Sprintf("[%s][%s %s]%s[-]", t.theme.logInfoColor, event.ID, event.Timestamp, skew),
+		)
+	}
+
+	ownColorTag := fmt.Sprintf("[%s]", t.theme.ownMsgColor)
+	ownNickTag := fmt.Sprintf("[%s::b]", t.theme.ownMsgColor)
+	if t.ownNickByPubKey {
+		ownNickTag = strings.TrimSuffix(t.nickColor(event.FullPubKey), "]") + "::b]"
+	}
+
+	status := ""
+	if event.LocalID != "" {
+		switch msg.status {
+		case client.MsgStatusSent:
+			status = fmt.Sprintf(" [%s]✓[-]", t.theme.titleColor)
+		case client.MsgStatusFailed:
+			status = fmt.Sprintf(" [%s]✗[-]", t.theme.logErrorColor)
+		default:
+			status = fmt.Sprintf(" [%s]…[-]", t.theme.logInfoColor)
 		}
+	}
+
+	return quote + t.layoutMessage(
+		fmt.Sprintf("%s%s%s[-::-]#%s", label, ownNickTag, nick, event.ShortPubKey),
+		fmt.Sprintf("%s%s[-]", ownColorTag, content),
+		fmt.Sprintf("[%s][%s %s]%s[-]%s", t.theme.logInfoColor, event.ID, event.Timestamp, skew, status),
+	)
+}
 
-		if event.IsOwnMessage {
-			fmt.Fprintf(
-				t.output,
-				"\n%s%s%s[-::-]#%s> %s%s[-] [%s][%s %s][-]",
-				label,
-				ownNickTag, event.Nick, event.ShortPubKey,
-				ownColorTag, content,
-				t.theme.logInfoColor, event.ID, event.Timestamp,
-			)
+// layoutMessage arranges the author, content and ID/time parts of a message
+// in the configured layout.
+func (t *tui) layoutMessage(author, content, meta string) string {
+	if t.messageLayout == client.LayoutExpanded {
+		return author + " " + meta + "\n" + t.wrapMessage(expandedIndent, content)
+	}
+	return t.wrapMessage(author+"> ", content+" "+meta)
+}
+
+// wrapMessage joins the prefix and text of a message line. With hanging
+// indent on, the text is wrapped to the output width and its continuation
+// lines are indented to where the text starts. Both must already be escaped,
+// or tags in remote content would be measured as markup.
+func (t *tui) wrapMessage(prefix, text string) string {
+	if !t.hangingIndent {
+		return prefix + text
+	}
+	_, _, width, _ := t.output.GetInnerRect()
+	indent := tview.TaggedStringWidth(prefix)
+	if width-indent < minWrapWidth {
+		return prefix + text
+	}
+	lines := tview.WordWrap(text, width-indent)
+	return prefix + strings.Join(lines, "\n"+strings.Repeat(" ", indent))
+}
+
+// rerenderAll re-renders every message in the output view, e.g. after its
+// width changed.
+func (t *tui) rerenderAll() {
+	msgs := make(map[string]*messageLine, len(t.renderedMsgs))
+	for _, r := range t.renderedMsgs {
+		msgs[r.region] = r.msg
+	}
+	text := t.output.GetText(false)
+	var b strings.Builder
+	for {
+		loc := regionTagRe.FindStringSubmatchIndex(text)
+		if loc == nil {
+			break
+		}
+		open := loc[1]
+		end := strings.Index(text[open:], `[""]`)
+		if end < 0 {
+			break
+		}
+		end += open
+		b.WriteString(text[:open])
+		if msg, ok := msgs[text[loc[2]:loc[3]]]; ok {
+			b.WriteString(t.formatMessage(msg))
 		} else {
-			fmt.Fprintf(
-				t.output,
-				"\n%s%s%s[-::-]#%s> %s [%s][%s %s][-]",
-				label,
-				nickColorTag, event.Nick, event.ShortPubKey,
-				content,
-				t.theme.logInfoColor, event.ID, event.Timestamp,
-			)
+			b.WriteString(text[open:end])
+		}
+		text = text[end:]
+	}
+	b.WriteString(text)
+	t.output.SetText(b.String())
+	_, _, t.wrapWidth, _ = t.output.GetInnerRect()
+}
+
+// highlight escapes content and wraps the given byte ranges of it in the
+// theme's highlight color.
+func (t *tui) highlight(content string, ranges [][2]int) string {
+	if len(ranges) == 0 {
+		return tview.Escape(content)
+	}
+	var b strings.Builder
+	prev := 0
+	for _, r := range ranges {
+		if r[0] < prev || r[1] > len(content) {
+			continue
+		}
+		b.WriteString(tview.Escape(content[prev:r[0]]))
+		fmt.Fprintf(&b, "[%s::b]%s[-::-]", t.theme.highlightColor, tview.Escape(content[r[0]:r[1]]))
+		prev = r[1]
+	}
+	b.WriteString(tview.Escape(content[prev:]))
+	return b.String()
+}
+
+// handleMessageStatus patches the delivery glyph of an own message in place.
+func (t *tui) handleMessageStatus(event client.DisplayEvent) {
+	msg, ok := t.pendingMsgs[event.LocalID]
+	if !ok {
+		return
+	}
+	msg.status = event.Content
+	if event.ID != "" {
+		msg.event.ID = event.ID
+		msg.event.FullID = event.FullID
+		t.rememberQuote(msg.event)
+	}
+	if msg.status != client.MsgStatusPending {
+		delete(t.pendingMsgs, event.LocalID)
+	}
+	t.rerender(msg)
+}
+
+// rerender replaces a message's region in the output view with its current rendering.
+func (t *tui) rerender(msg *messageLine) {
+	if msg.region == "" {
+		return // held while paused, rendered on resume
+	}
+	text := t.output.GetText(false)
+	start, end, ok := regionSpan(text, msg.region)
+	if !ok {
+		return
+	}
+	t.output.SetText(text[:start] + t.formatMessage(msg) + text[end:])
+}
+
+// regionSpan returns where the content of region starts and ends in text.
+// formatMessage escapes what users write, so the region's tags appear
+// nowhere else and its first [""] is its own end.
+func regionSpan(text, region string) (start, end int, ok bool) {
+	open := fmt.Sprintf("[\"%s\"]", region)
+	start = strings.Index(text, open)
+	if start < 0 {
+		return 0, 0, false
+	}
+	start += len(open)
+	end = strings.Index(text[start:], `[""]`)
+	if end < 0 {
+		return 0, 0, false
+	}
+	return start, start + end, true
+}
+
+// activeViewName returns the name of the active view, or "" if there is none.
+func (t *tui) activeViewName() string {
+	if t.activeViewIndex < 0 || t.activeViewIndex >= len(t.views) {
+		return ""
+	}
+	return t.views[t.activeViewIndex].Name
+}
+
+// rememberURLs stores URLs found in a rendered message, keeping only the most recent ones.
+func (t *tui) rememberURLs(content string) {
+	for _, u := range urlRe.FindAllString(content, -1) {
+		t.recentURLs = append(t.recentURLs, u)
+	}
+	if len(t.recentURLs) > maxRecentURLs {
+		t.recentURLs = t.recentURLs[len(t.recentURLs)-maxRecentURLs:]
+	}
+}
+
+// openLastURL opens the most recent URL of the active view with the OS opener.
+func (t *tui) openLastURL() {
+	if t.disableURLOpen {
+		t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "Opening URLs is disabled in the config."})
+		return
+	}
+	if len(t.recentURLs) == 0 {
+		t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "No URL to open in the active chat."})
+		return
+	}
+	u := t.recentURLs[len(t.recentURLs)-1]
+	if err := openURL(u); err != nil {
+		t.handleLogMessage(client.DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Could not open %s: %v", u, err)})
+		return
+	}
+	t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "Opened " + u})
+}
+
+// whoisLastMessage shows the full identity of the last message's sender.
+func (t *tui) whoisLastMessage() {
+	if t.lastMessage == nil {
+		t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "No message in the active chat."})
+		return
+	}
+	t.actionsChan <- client.UserAction{Type: "WHOIS", Payload: t.lastMessage.FullPubKey}
+}
+
+// copyLastMessage copies the content or author pubkey of the most recently
+// rendered message to the clipboard using OSC 52, which also works over SSH.
+func (t *tui) copyLastMessage(pubkey bool) {
+	if t.lastMessage == nil {
+		t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: "No message to copy in the active chat."})
+		return
+	}
+	if t.screen == nil {
+		return
+	}
+
+	m := t.lastMessage
+	if pubkey {
+		t.screen.SetClipboard([]byte(m.FullPubKey))
+		t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Copied pubkey of %s#%s.", m.Nick, m.ShortPubKey)})
+		return
+	}
+	content := m.Content
+	if m.RawContent != "" {
+		content = m.RawContent
+	}
+	t.screen.SetClipboard([]byte(content))
+	t.handleLogMessage(client.DisplayEvent{Type: "STATUS", Content: fmt.Sprintf("Copied message %s from %s#%s.", m.ID, m.Nick, m.ShortPubKey)})
+}
+
+// notifyLevel returns the notification level for messages in chat: the one
+// set on the chat itself, or else on the active group showing it.
+func (t *tui) notifyLevel(chat string) string {
+	level := ""
+	for _, v := range t.views {
+		if v.Name == chat {
+			level = v.Notify
+			break
+		}
+	}
+	if level == "" && t.activeViewIndex >= 0 && t.activeViewIndex < len(t.views) {
+		if active := t.views[t.activeViewIndex]; active.IsGroup && slices.Contains(active.Children, chat) {
+			level = active.Notify
 		}
 	}
-	if !t.outputMaximized {
-		t.output.ScrollToEnd()
+	if level == "" {
+		return client.NotifyMentions
+	}
+	return level
+}
+
+// notifyMention rings the terminal bell and flags the Messages title
+// when the output view is not focused, unless do-not-disturb is on.
+func (t *tui) notifyMention() {
+	if t.dnd {
+		return
+	}
+	if t.screen != nil {
+		_ = t.screen.Beep()
+	}
+	if !t.output.HasFocus() {
+		t.mentionPending = true
+		t.updateOutputTitle()
 	}
 }
 
@@ -398,9 +927,7 @@ func (t *tui) handleNewMessage(event client.DisplayEvent) {
 func (t *tui) handleInfoMessage(event client.DisplayEvent) {
 	content := tview.Escape(strings.TrimSpace(event.Content))
 	fmt.Fprintf(t.output, "\n[%s]-- %s[-]", t.theme.titleColor, content)
-	if !t.outputMaximized {
-		t.output.ScrollToEnd()
-	}
+	t.followOutput()
 }
 
 // handleLogMessage displays a status or error message in the logs view.
@@ -409,12 +936,20 @@ func (t *tui) handleLogMessage(event client.DisplayEvent) {
 	if event.Type == "ERROR" {
 		color = t.theme.logErrorColor
 	}
-	fmt.Fprintf(t.logs, "\n[%s][%s] %s: %s[-]", color, time.Now().Format("15:04:05"), event.Type, event.Content)
+	fmt.Fprintf(t.logs, "\n[%s][%s] %s: %s[-]", color, time.Now().Format("15:04:05"), event.Type, tview.Escape(event.Content))
 	if !t.logsMaximized {
 		t.logs.ScrollToEnd()
 	}
 }
 
+// handlePoWProgress shows PoW mining progress in the status line, advancing
+// the spinner on each update. An empty Content means mining has ended.
+func (t *tui) handlePoWProgress(event client.DisplayEvent) {
+	t.powProgress = event.Content
+	t.powSpin++
+	t.updateStatusLine()
+}
+
 // handleStateUpdate updates the TUI's state based on data from the client.
 func (t *tui) handleStateUpdate(event client.DisplayEvent) {
 	state, ok := event.Payload.(client.StateUpdate)
@@ -422,12 +957,55 @@ func (t *tui) handleStateUpdate(event client.DisplayEvent) {
 		fmt.Fprintf(t.logs, "\n[%s]ERROR: Invalid STATE_UPDATE payload[-]", t.theme.logErrorColor)
 		return
 	}
+	prevActive := t.activeViewName()
 	t.views = state.Views
 	t.activeViewIndex = state.ActiveViewIndex
 	t.nick = state.Nick
+	t.pow = state.PoW
+	if state.MaxMsgLen > 0 {
+		t.maxMsgLen = state.MaxMsgLen
+	}
+	t.truncateDisplay = state.TruncateDisplay
+	t.relaySort = state.RelaySort
+	if state.HangingIndent != t.hangingIndent || state.MessageLayout != t.messageLayout {
+		t.hangingIndent = state.HangingIndent
+		t.messageLayout = state.MessageLayout
+		t.rerenderAll()
+	}
+	t.bellOnMention = state.BellOnMention
+	t.disableURLOpen = state.DisableURLOpen
+	t.confirmDeletes = state.ConfirmDeletes
+	t.nickColors = state.NickColors
+	t.ownNickByPubKey = state.OwnNickByPubKey
+	t.screenWrap.noColor = state.NoColor || t.forceNoColor
+	t.app.EnableMouse(state.Mouse)
+	if !maps.Equal(state.Keybindings, t.keybindings) {
+		t.keybindings = state.Keybindings
+		t.applyKeybindings(state.Keybindings)
+		t.updateTitles()
+		t.updateHints()
+	}
+	if theme := state.Theme; theme != "" && theme != t.themeName {
+		if !t.setTheme(theme) {
+			t.handleLogMessage(client.DisplayEvent{Type: "ERROR", Content: fmt.Sprintf("Unknown theme '%s' in config.", theme)})
+		}
+	}
+	if active := t.activeViewName(); active != prevActive {
+		t.recentURLs = nil
+		t.lastMessage = nil
+		t.swapDraft(prevActive, active)
+	}
+	if t.activeViewIndex >= 0 && t.activeViewIndex < len(t.views) {
+		active := t.views[t.activeViewIndex]
+		delete(t.unread, active.Name)
+		for _, child := range active.Children {
+			delete(t.unread, child)
+		}
+	}
 	t.updateChatList()
 	t.updateDetailsView()
 	t.updateInputLabel()
+	t.updateStatusLine()
 }
 
 // handleRelaysUpdate refreshes the list of relays.
@@ -439,6 +1017,7 @@ func (t *tui) handleRelaysUpdate(event client.DisplayEvent) {
 	}
 	t.relays = relays
 	t.updateDetailsView()
+	t.updateStatusLine()
 }
 
 // handleNickCompletion provides completion entries to the input field.
@@ -454,5 +1033,22 @@ func (t *tui) handleNickCompletion(event client.DisplayEvent) {
 
 // Run starts the TUI application.
 func (t *tui) Run() error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	t.screenWrap.Screen = screen
+	if err := t.screenWrap.Init(); err != nil {
+		return err
+	}
+	t.app.SetScreen(t.screenWrap)
 	return t.app.Run()
 }
+
+// SetVersion records the build information shown by /version.
+func (t *tui) SetVersion(version, commit, date string) {
+	if date == "" {
+		date = "unknown"
+	}
+	t.version = fmt.Sprintf("strchat-tui %s (commit %s, built %s, %s)", version, commit, date, runtime.Version())
+}
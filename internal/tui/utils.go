@@ -1,11 +1,31 @@
 package tui
 
 import (
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"runtime"
 	"strings"
 
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/uniseg"
 )
 
+const maxRecentURLs = 20
+
+// minNickContrast is the WCAG AA contrast ratio nick colors are adjusted to
+// against the theme background.
+const minNickContrast = 4.5
+
+// Nick color strategies selectable with the nick_colors config key.
+const (
+	nickColorsPalette = "palette"
+	nickColorsHSL     = "hsl"
+)
+
+var urlRe = regexp.MustCompile(`https?://[^\s\[\]<>"]+`)
+
 // extractNickPrefix finds a potential nick prefix (e.g., "@user#1234") at the end of a string.
 // It returns the found nick and a boolean indicating if the nick is complete (has a valid tag).
 func extractNickPrefix(s string) (nick string, complete bool) {
@@ -53,6 +73,100 @@ func pubkeyToColor(pubkey string, palette []string) string {
 	return palette[int(sum)%len(palette)]
 }
 
+// nickColor returns the color tag for a pubkey using the configured strategy,
+// made readable on the theme background.
+func (t *tui) nickColor(pubkey string) string {
+	tag := pubkeyToColor(pubkey, t.theme.nickPalette)
+	if t.nickColors == nickColorsHSL {
+		tag = pubkeyToHSLColor(pubkey)
+	}
+	return readableOn(tag, t.theme.backgroundColor)
+}
+
+// readableOn blends a color tag toward white (dark backgrounds) or black
+// (light backgrounds) until it reaches minNickContrast against bg. Tags
+// whose colors have no known RGB value are returned unchanged.
+func readableOn(tag string, bg tcell.Color) string {
+	fr, fg, fb := tcell.GetColor(strings.Trim(tag, "[]")).RGB()
+	br, bgr, bb := bg.RGB()
+	if fr < 0 || br < 0 {
+		return tag
+	}
+	bgLum := luminance(br, bgr, bb)
+	if contrastRatio(luminance(fr, fg, fb), bgLum) >= minNickContrast {
+		return tag
+	}
+
+	target := 255.0
+	if bgLum > 0.5 {
+		target = 0
+	}
+	r, g, b := float64(fr), float64(fg), float64(fb)
+	for range 10 {
+		r, g, b = r+(target-r)*0.2, g+(target-g)*0.2, b+(target-b)*0.2
+		if contrastRatio(luminance(int32(r), int32(g), int32(b)), bgLum) >= minNickContrast {
+			break
+		}
+	}
+	return fmt.Sprintf("[#%02x%02x%02x]", int(r), int(g), int(b))
+}
+
+// luminance is the WCAG relative luminance of an sRGB color.
+func luminance(r, g, b int32) float64 {
+	lin := func(v int32) float64 {
+		c := float64(v) / 255
+		if c <= 0.03928 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	return 0.2126*lin(r) + 0.7152*lin(g) + 0.0722*lin(b)
+}
+
+// contrastRatio is the WCAG contrast ratio between two luminances.
+func contrastRatio(a, b float64) float64 {
+	return (max(a, b) + 0.05) / (min(a, b) + 0.05)
+}
+
+// pubkeyToHSLColor derives a stable color from a pubkey. The hue spans the
+// full circle while saturation and lightness stay in a readable band.
+func pubkeyToHSLColor(pubkey string) string {
+	var h uint32 = 2166136261 // FNV-1a
+	for i := 0; i < len(pubkey); i++ {
+		h ^= uint32(pubkey[i])
+		h *= 16777619
+	}
+	hue := float64(h % 360)
+	sat := 0.55 + float64((h>>9)%4)*0.1
+	light := 0.55 + float64((h>>11)%3)*0.08
+	return fmt.Sprintf("[%s]", hslToHex(hue, sat, light))
+}
+
+// hslToHex converts hue (degrees), saturation and lightness (0-1) to #rrggbb.
+func hslToHex(h, s, l float64) string {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	to8 := func(v float64) int { return int(math.Round((v + m) * 255)) }
+	return fmt.Sprintf("#%02x%02x%02x", to8(r), to8(g), to8(b))
+}
+
 // graphemeLen counts user-perceived characters (grapheme clusters)
 // to handle emoji and ZWJ sequences correctly in TUI input fields.
 func graphemeLen(s string) int {
@@ -63,3 +177,31 @@ func graphemeLen(s string) int {
 	}
 	return count
 }
+
+// truncateGraphemes cuts s to at most n grapheme clusters and reports how
+// many were cut off.
+func truncateGraphemes(s string, n int) (string, int) {
+	g := uniseg.NewGraphemes(s)
+	end, count := 0, 0
+	for g.Next() {
+		if count < n {
+			_, end = g.Positions()
+		}
+		count++
+	}
+	return s[:end], max(count-n, 0)
+}
+
+// openURL opens a URL with the platform's default handler.
+func openURL(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,77 @@
+// Package strchat exposes the strchat client core for alternative
+// front-ends and bots.
+//
+// A front-end talks to a Client over two channels it creates and owns:
+//
+//   - actions carries UserAction values to the client. Type names the
+//     action (e.g. "JOIN_CHATS", "SET_NICK", "SEND_MESSAGE", "QUIT") and
+//     Payload its argument, as the slash commands of the TUI send them.
+//     Sending "QUIT" shuts the client down.
+//   - events carries DisplayEvent values from the client. The front-end
+//     must keep reading it: the client blocks while the channel is full.
+//     Type says what happened ("NEW_MESSAGE", "MESSAGE_STATUS", "STATUS",
+//     "INFO", "ERROR", ...). "STATE_UPDATE" events carry a StateUpdate and
+//     "RELAYS_UPDATE" events a []RelayInfo in Payload. "SHUTDOWN" is the
+//     last event sent; no more follow it.
+//
+// The client never closes either channel.
+package strchat
+
+import (
+	"github.com/lessucettes/strchat-tui/internal/client"
+)
+
+// Types exchanged over the actions and events channels.
+type (
+	UserAction   = client.UserAction
+	DisplayEvent = client.DisplayEvent
+	StateUpdate  = client.StateUpdate
+	View         = client.View
+	RelayInfo    = client.RelayInfo
+)
+
+// Delivery states sent as the Content of MESSAGE_STATUS events.
+const (
+	MsgStatusPending = client.MsgStatusPending
+	MsgStatusSent    = client.MsgStatusSent
+	MsgStatusFailed  = client.MsgStatusFailed
+)
+
+// Notification levels of a view, found in View.Notify.
+const (
+	NotifyAll      = client.NotifyAll
+	NotifyMentions = client.NotifyMentions
+	NotifyNone     = client.NotifyNone
+)
+
+// Client is a Nostr chat client driven over its actions and events channels.
+type Client struct {
+	impl interface{ Run() }
+}
+
+// New loads the configuration and creates a client reading actions and
+// writing events. Call Run to start it.
+func New(actions <-chan UserAction, events chan<- DisplayEvent) (*Client, error) {
+	c, err := client.New(actions, events)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{impl: c}, nil
+}
+
+// Run connects to the relays of the active view and handles actions until
+// a QUIT action. It blocks, so front-ends usually start it in a goroutine.
+func (c *Client) Run() {
+	c.impl.Run()
+}
+
+// SetConfigDir makes all configuration and state files live under dir
+// instead of the user config directory. It must be called before New.
+func SetConfigDir(dir string) error {
+	return client.SetConfigDir(dir)
+}
+
+// ConfigDir returns the directory holding the configuration files.
+func ConfigDir() (string, error) {
+	return client.ConfigDir()
+}